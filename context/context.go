@@ -0,0 +1,30 @@
+package context
+
+import (
+	"context"
+
+	"github.com/uhdang/lenslocked/models"
+)
+
+// privateKey is an unexported type used for context keys defined
+// in this package so that they never collide with keys defined in
+// other packages.
+type privateKey string
+
+const userKey privateKey = "user"
+
+// WithUser returns a new context with the provided user attached.
+func WithUser(ctx context.Context, user *models.User) context.Context {
+	return context.WithValue(ctx, userKey, user)
+}
+
+// User returns the user stored in the context, or nil if no user
+// is present.
+func User(ctx context.Context) *models.User {
+	if temp := ctx.Value(userKey); temp != nil {
+		if user, ok := temp.(*models.User); ok {
+			return user
+		}
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/uhdang/lenslocked/models"
+	"github.com/uhdang/lenslocked/views"
+)
+
+const (
+	defaultUserPageSize = 20
+	maxUserPageSize     = 100
+)
+
+// NewAdmin returns an Admin controller with its views already
+// parsed and ready to be rendered.
+func NewAdmin(us models.UserService) *Admin {
+	return &Admin{
+		ListView: views.NewView("bootstrap", "admin/users_index"),
+		EditView: views.NewView("bootstrap", "admin/users_edit"),
+		us:       us,
+	}
+}
+
+type Admin struct {
+	ListView *views.View
+	EditView *views.View
+	us       models.UserService
+}
+
+// userListData is the JSON representation of a single row returned
+// by List, deliberately narrower than models.User so we never leak
+// PasswordHash, RememberHash, or verification tokens.
+type userListData struct {
+	ID       uint   `json:"id"`
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Role     string `json:"role"`
+	Disabled bool   `json:"disabled"`
+}
+
+// GET /admin/users
+//
+// List returns a page of users matching the optional query
+// parameter against name/email. Requests that Accept
+// application/json get a JSON array with X-Total-Count and RFC-5988
+// Link headers describing prev/next pages; everything else gets the
+// HTML browser UI.
+func (a *Admin) List(w http.ResponseWriter, r *http.Request) {
+	query := r.FormValue("query")
+	page := parsePositiveInt(r.FormValue("page"), 1)
+	pageSize := parsePositiveInt(r.FormValue("page_size"), defaultUserPageSize)
+	if pageSize > maxUserPageSize {
+		pageSize = maxUserPageSize
+	}
+
+	users, total, err := a.us.Search(query, page, pageSize)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if wantsJSON(r) {
+		a.writeJSON(w, r, users, total, page, pageSize)
+		return
+	}
+
+	if err := a.ListView.Render(w, r, views.Data{Yield: users}); err != nil {
+		panic(err)
+	}
+}
+
+func (a *Admin) writeJSON(w http.ResponseWriter, r *http.Request, users []models.User, total, page, pageSize int) {
+	data := make([]userListData, len(users))
+	for i, user := range users {
+		data[i] = userListData{
+			ID:       user.ID,
+			Name:     user.Name,
+			Email:    user.Email,
+			Role:     user.Role,
+			Disabled: user.Disabled,
+		}
+	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	if link := paginationLink(r, total, page, pageSize); link != "" {
+		w.Header().Set("Link", link)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// paginationLink builds an RFC-5988 Link header value with rel="prev"
+// and rel="next" entries, omitting whichever side doesn't exist.
+func paginationLink(r *http.Request, total, page, pageSize int) string {
+	var links []string
+	if page > 1 {
+		links = append(links, fmt.Sprintf(`<%s>; rel="prev"`, pageURL(r, page-1)))
+	}
+	if page*pageSize < total {
+		links = append(links, fmt.Sprintf(`<%s>; rel="next"`, pageURL(r, page+1)))
+	}
+	return strings.Join(links, ", ")
+}
+
+func pageURL(r *http.Request, page int) string {
+	u := *r.URL
+	u.Scheme = ""
+	u.Host = ""
+	q := u.Query()
+	q.Set("page", strconv.Itoa(page))
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+func wantsJSON(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+func parsePositiveInt(s string, def int) int {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return def
+	}
+	return n
+}
+
+// AdminUserForm is submitted by the edit view to update a user's
+// Name, Email, Age, and Role.
+type AdminUserForm struct {
+	Name  string `schema:"name"`
+	Email string `schema:"email"`
+	Age   int    `schema:"age"`
+	Role  string `schema:"role"`
+}
+
+// GET /admin/users/{id}
+func (a *Admin) Edit(w http.ResponseWriter, r *http.Request) {
+	user, err := a.userFromPath(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err := a.EditView.Render(w, r, views.Data{Yield: user}); err != nil {
+		panic(err)
+	}
+}
+
+// POST /admin/users/{id}
+func (a *Admin) Update(w http.ResponseWriter, r *http.Request) {
+	user, err := a.userFromPath(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	var form AdminUserForm
+	if err := parseForm(r, &form); err != nil {
+		panic(err)
+	}
+	user.Name = form.Name
+	user.Email = form.Email
+	user.Age = form.Age
+	user.Role = form.Role
+	if err := a.us.Update(user); err != nil {
+		var data views.Data
+		data.Yield = user
+		data.SetAlert(err)
+		if err := a.EditView.Render(w, r, data); err != nil {
+			panic(err)
+		}
+		return
+	}
+	http.Redirect(w, r, "/admin/users", http.StatusFound)
+}
+
+// POST /admin/users/{id}/disable
+func (a *Admin) Disable(w http.ResponseWriter, r *http.Request) {
+	user, err := a.userFromPath(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	user.Disabled = true
+	if err := a.us.Update(user); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin/users", http.StatusFound)
+}
+
+// DELETE /admin/users/{id}
+func (a *Admin) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := idFromPath(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err := a.us.Delete(id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if wantsJSON(r) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	http.Redirect(w, r, "/admin/users", http.StatusFound)
+}
+
+func (a *Admin) userFromPath(r *http.Request) (*models.User, error) {
+	id, err := idFromPath(r)
+	if err != nil {
+		return nil, err
+	}
+	return a.us.ByID(id)
+}
+
+func idFromPath(r *http.Request) (uint, error) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return uint(id), nil
+}
@@ -16,7 +16,7 @@ type Galleries struct {
 }
 
 func (g *Galleries) New(w http.ResponseWriter, r *http.Request) {
-	if err := g.NewView.Render(w, nil); err != nil {
+	if err := g.NewView.Render(w, r, nil); err != nil {
 		panic(err)
 	}
 }
@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/uhdang/lenslocked/context"
+	"github.com/uhdang/lenslocked/models"
+)
+
+// UserMiddleware looks up the current user via the remember_token
+// cookie and can be embedded by other middleware that need access
+// to the UserService.
+type UserMiddleware struct {
+	models.UserService
+}
+
+// Apply wraps the given handler so that the current user, if any,
+// is attached to the request context before calling through.
+func (mw *UserMiddleware) Apply(next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie("remember_token")
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		user, err := mw.ByRemember(cookie.Value)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx := context.WithUser(r.Context(), user)
+		r = r.WithContext(ctx)
+		next.ServeHTTP(w, r)
+	}
+}
+
+// RequireUser is middleware that requires a user be known via the
+// remember_token cookie before allowing a request through. It
+// redirects to /login when no user is found.
+type RequireUser struct {
+	UserMiddleware
+}
+
+func (mw *RequireUser) Apply(next http.Handler) http.HandlerFunc {
+	return mw.ApplyFn(next.ServeHTTP)
+}
+
+// ApplyFn is the same as Apply except it accepts and returns a
+// http.HandlerFunc instead of a http.Handler.
+func (mw *RequireUser) ApplyFn(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := context.User(r.Context())
+		if user == nil {
+			http.Redirect(w, r, "/login", http.StatusFound)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// RequireRole is middleware that requires the current user have the
+// given Role, e.g. models.RoleAdmin. It builds on RequireUser, so it
+// also redirects to /login when no user is found at all.
+type RequireRole struct {
+	RequireUser
+	Role string
+}
+
+func (mw *RequireRole) Apply(next http.Handler) http.HandlerFunc {
+	return mw.ApplyFn(next.ServeHTTP)
+}
+
+// ApplyFn is the same as Apply except it accepts and returns a
+// http.HandlerFunc instead of a http.Handler.
+func (mw *RequireRole) ApplyFn(next http.HandlerFunc) http.HandlerFunc {
+	return mw.RequireUser.ApplyFn(func(w http.ResponseWriter, r *http.Request) {
+		user := context.User(r.Context())
+		if user.Role != mw.Role {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	})
+}
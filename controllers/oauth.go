@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/oauth2"
+
+	"github.com/uhdang/lenslocked/models"
+	"github.com/uhdang/lenslocked/oauth"
+	"github.com/uhdang/lenslocked/rand"
+)
+
+// NewOAuth returns an OAuth controller backed by the given model
+// services and provider registry.
+func NewOAuth(us models.UserService, connections models.OAuthConnectionService, registry *oauth.Registry) *OAuth {
+	return &OAuth{
+		us:          us,
+		connections: connections,
+		registry:    registry,
+	}
+}
+
+type OAuth struct {
+	us          models.UserService
+	connections models.OAuthConnectionService
+	registry    *oauth.Registry
+}
+
+// GET /oauth/{provider}/login
+func (o *OAuth) Login(w http.ResponseWriter, r *http.Request) {
+	provider, ok := o.provider(w, r)
+	if !ok {
+		return
+	}
+	state, err := rand.String(32)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     "oauth_state",
+		Value:    state,
+		HttpOnly: true,
+		Expires:  time.Now().Add(10 * time.Minute),
+	})
+	http.Redirect(w, r, provider.Config.AuthCodeURL(state), http.StatusFound)
+}
+
+// GET /oauth/{provider}/callback
+func (o *OAuth) Callback(w http.ResponseWriter, r *http.Request) {
+	provider, ok := o.provider(w, r)
+	if !ok {
+		return
+	}
+	cookie, err := r.Cookie("oauth_state")
+	if err != nil || cookie.Value == "" || cookie.Value != r.FormValue("state") {
+		http.Error(w, "Invalid OAuth state.", http.StatusBadRequest)
+		return
+	}
+	token, err := provider.Config.Exchange(r.Context(), r.FormValue("code"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	identity, err := provider.FetchIdentity(r.Context(), token)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	user, err := o.userFor(provider.Name, identity, token)
+	if err == models.ErrUserDisabled {
+		http.Error(w, "This account has been disabled.", http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := signIn(w, o.us, user); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintln(w, "Signed in as", user.Email)
+}
+
+func (o *OAuth) provider(w http.ResponseWriter, r *http.Request) (*oauth.Provider, bool) {
+	name := mux.Vars(r)["provider"]
+	provider, ok := o.registry.Get(name)
+	if !ok {
+		http.Error(w, "Unknown OAuth provider.", http.StatusNotFound)
+		return nil, false
+	}
+	return provider, true
+}
+
+// userFor resolves the local user for a provider identity, linking
+// an existing OAuthConnection if one exists or creating both a new
+// user and connection otherwise.
+func (o *OAuth) userFor(providerName string, identity *oauth.Identity, token *oauth2.Token) (*models.User, error) {
+	conn, err := o.connections.ByProviderSubject(providerName, identity.Subject)
+	switch err {
+	case nil:
+		user, err := o.us.ByID(conn.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if user.Disabled {
+			return nil, models.ErrUserDisabled
+		}
+		conn.AccessToken = token.AccessToken
+		conn.RefreshToken = token.RefreshToken
+		conn.Expiry = token.Expiry
+		if err := o.connections.Update(conn); err != nil {
+			return nil, err
+		}
+		return user, nil
+	case models.ErrNotFound:
+		password, err := rand.String(16)
+		if err != nil {
+			return nil, err
+		}
+		user := models.User{
+			Name:     identity.Name,
+			Email:    identity.Email,
+			Password: password,
+		}
+		if err := o.us.Create(&user); err != nil {
+			return nil, err
+		}
+		conn := models.OAuthConnection{
+			UserID:          user.ID,
+			Provider:        providerName,
+			ProviderSubject: identity.Subject,
+			AccessToken:     token.AccessToken,
+			RefreshToken:    token.RefreshToken,
+			Expiry:          token.Expiry,
+		}
+		if err := o.connections.Create(&conn); err != nil {
+			return nil, err
+		}
+		return &user, nil
+	default:
+		return nil, err
+	}
+}
@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uhdang/lenslocked/hash"
+	"github.com/uhdang/lenslocked/models"
+	"github.com/uhdang/lenslocked/rand"
+)
+
+// signIn signs the given user in via cookie, setting a remember
+// token cookie and regenerating the token if one has not been set
+// yet. It is shared by every controller that authenticates a user,
+// e.g. Users and OAuth.
+func signIn(w http.ResponseWriter, us models.UserService, user *models.User) error {
+	if user.Remember == "" {
+		token, err := rand.RememberToken()
+		if err != nil {
+			return err
+		}
+		user.Remember = token
+		if err := us.Update(user); err != nil {
+			return err
+		}
+	}
+	cookie := http.Cookie{
+		Name:     "remember_token",
+		Value:    user.Remember,
+		HttpOnly: true,
+		Expires:  time.Now().Add(30 * 24 * time.Hour),
+	}
+	http.SetCookie(w, &cookie)
+	return nil
+}
+
+const (
+	pendingLoginCookieName = "pending_2fa"
+	pendingLoginDuration   = 5 * time.Minute
+	pendingLoginSecretKey  = "secret-pending-login-key"
+)
+
+var pendingLoginHMAC = hash.NewHMAC(pendingLoginSecretKey)
+
+// setPendingLoginCookie records that userID has passed password
+// authentication but still owes a 2FA code, so the /login/2fa step
+// knows which user to validate the code against. The cookie value is
+// signed so it can't be forged into signing in as a different user.
+func setPendingLoginCookie(w http.ResponseWriter, userID uint) {
+	value := strconv.FormatUint(uint64(userID), 10)
+	cookie := http.Cookie{
+		Name:     pendingLoginCookieName,
+		Value:    value + "|" + pendingLoginHMAC.Hash(value),
+		HttpOnly: true,
+		Expires:  time.Now().Add(pendingLoginDuration),
+	}
+	http.SetCookie(w, &cookie)
+}
+
+// pendingLoginUserID returns the user ID recorded by
+// setPendingLoginCookie, or ok=false if the cookie is missing,
+// expired, or has been tampered with.
+func pendingLoginUserID(r *http.Request) (userID uint, ok bool) {
+	cookie, err := r.Cookie(pendingLoginCookieName)
+	if err != nil {
+		return 0, false
+	}
+	value, sig, found := strings.Cut(cookie.Value, "|")
+	if !found || pendingLoginHMAC.Hash(value) != sig {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// clearPendingLoginCookie deletes the pending_2fa cookie once the
+// 2FA step has succeeded or is no longer needed.
+func clearPendingLoginCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:    pendingLoginCookieName,
+		Value:   "",
+		Expires: time.Unix(0, 0),
+		MaxAge:  -1,
+	})
+}
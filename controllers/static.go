@@ -0,0 +1,19 @@
+package controllers
+
+import "github.com/uhdang/lenslocked/views"
+
+// NewStatic returns a Static controller with its views already
+// parsed and ready to be rendered.
+func NewStatic() *Static {
+	return &Static{
+		Home:    views.NewView("bootstrap", "static/home"),
+		Contact: views.NewView("bootstrap", "static/contact"),
+		Faq:     views.NewView("bootstrap", "static/faq"),
+	}
+}
+
+type Static struct {
+	Home    *views.View
+	Contact *views.View
+	Faq     *views.View
+}
@@ -0,0 +1,165 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image/png"
+	"net/http"
+
+	"github.com/pquerna/otp"
+
+	"github.com/uhdang/lenslocked/context"
+	"github.com/uhdang/lenslocked/models"
+	"github.com/uhdang/lenslocked/views"
+)
+
+// totpIssuer is embedded in every otpauth:// URL so authenticator
+// apps label the entry consistently.
+const totpIssuer = "LensLocked"
+
+// NewTwoFactor returns a TwoFactor controller with its views already
+// parsed and ready to be rendered.
+func NewTwoFactor(us models.UserService) *TwoFactor {
+	return &TwoFactor{
+		EnrollView:        views.NewView("bootstrap", "account/totp_enroll"),
+		RecoveryCodesView: views.NewView("bootstrap", "account/totp_recovery_codes"),
+		LoginView:         views.NewView("bootstrap", "account/totp_login"),
+		us:                us,
+	}
+}
+
+type TwoFactor struct {
+	EnrollView        *views.View
+	RecoveryCodesView *views.View
+	LoginView         *views.View
+	us                models.UserService
+}
+
+type enrollData struct {
+	Secret    string
+	QRCodeB64 string
+}
+
+// GET /account/2fa/enroll
+//
+// Enroll generates a new TOTP secret for the current user and shows
+// it, along with a QR code, so they can add it to an authenticator
+// app before confirming with a code.
+func (tf *TwoFactor) Enroll(w http.ResponseWriter, r *http.Request) {
+	user := context.User(r.Context())
+	secret, otpauthURL, err := tf.us.BeginTOTPEnrollment(user, totpIssuer)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	qrCode, err := qrCodeDataURI(otpauthURL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	data := views.Data{Yield: enrollData{Secret: secret, QRCodeB64: qrCode}}
+	if err := tf.EnrollView.Render(w, r, data); err != nil {
+		panic(err)
+	}
+}
+
+type ConfirmEnrollForm struct {
+	Code string `schema:"code"`
+}
+
+// POST /account/2fa/enroll
+//
+// ConfirmEnroll validates the submitted code against the pending
+// secret from Enroll. On success it enables 2FA and shows a set of
+// recovery codes that are never shown again.
+func (tf *TwoFactor) ConfirmEnroll(w http.ResponseWriter, r *http.Request) {
+	user := context.User(r.Context())
+	var form ConfirmEnrollForm
+	if err := parseForm(r, &form); err != nil {
+		panic(err)
+	}
+	recoveryCodes, err := tf.us.ConfirmTOTPEnrollment(user, form.Code)
+	if err != nil {
+		var data views.Data
+		data.Yield = form
+		data.SetAlert(models.ValidationError{"code": "Invalid code. Please try again."})
+		if err := tf.EnrollView.Render(w, r, data); err != nil {
+			panic(err)
+		}
+		return
+	}
+	if err := tf.RecoveryCodesView.Render(w, r, views.Data{Yield: recoveryCodes}); err != nil {
+		panic(err)
+	}
+}
+
+// POST /account/2fa/disable
+func (tf *TwoFactor) Disable(w http.ResponseWriter, r *http.Request) {
+	user := context.User(r.Context())
+	if err := tf.us.DisableTOTP(user); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+type LoginTOTPForm struct {
+	Code string `schema:"code"`
+}
+
+// POST /login/2fa
+//
+// Login is the second step of signing in for a user with 2FA
+// enabled: it consumes the pending_2fa cookie Users.Login set after
+// a correct password, and validates the submitted code as either a
+// TOTP code or a recovery code.
+func (tf *TwoFactor) Login(w http.ResponseWriter, r *http.Request) {
+	var form LoginTOTPForm
+	if err := parseForm(r, &form); err != nil {
+		panic(err)
+	}
+	userID, ok := pendingLoginUserID(r)
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+	user, err := tf.us.ByID(userID)
+	if err != nil || user.Disabled {
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+	if err := tf.us.ValidateTOTP(user, form.Code); err != nil {
+		var data views.Data
+		data.Yield = form
+		data.SetAlert(models.ValidationError{"code": "Invalid code. Please try again."})
+		if err := tf.LoginView.Render(w, r, data); err != nil {
+			panic(err)
+		}
+		return
+	}
+	clearPendingLoginCookie(w)
+	if err := signIn(w, tf.us, user); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintln(w, user)
+}
+
+// qrCodeDataURI renders otpauthURL as a PNG QR code and returns it as
+// a base64-encoded data URI an <img> tag can use directly.
+func qrCodeDataURI(otpauthURL string) (string, error) {
+	key, err := otp.NewKeyFromURL(otpauthURL)
+	if err != nil {
+		return "", err
+	}
+	img, err := key.Image(200, 200)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
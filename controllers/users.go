@@ -2,28 +2,38 @@ package controllers
 
 import (
 	"fmt"
+	"net/http"
+
+	"github.com/uhdang/lenslocked/mail"
 	"github.com/uhdang/lenslocked/models"
 	"github.com/uhdang/lenslocked/views"
-	"net/http"
 )
 
-func NewUsers(us *models.UserService) *Users {
+func NewUsers(us models.UserService, pwResets models.PasswordResetService, emailer mail.Client) *Users {
 	return &Users{
-		NewView:   views.NewView("bootstrap", "users/new"),
-		LoginView: views.NewView("bootstrap", "users/login"),
-		us:        us,
+		NewView:      views.NewView("bootstrap", "users/new"),
+		LoginView:    views.NewView("bootstrap", "users/login"),
+		ForgotPwView: views.NewView("bootstrap", "users/forgot_pw"),
+		ResetPwView:  views.NewView("bootstrap", "users/reset_pw"),
+		us:           us,
+		pwResets:     pwResets,
+		emailer:      emailer,
 	}
 }
 
 type Users struct {
-	NewView   *views.View
-	LoginView *views.View
-	us        *models.UserService
+	NewView      *views.View
+	LoginView    *views.View
+	ForgotPwView *views.View
+	ResetPwView  *views.View
+	us           models.UserService
+	pwResets     models.PasswordResetService
+	emailer      mail.Client
 }
 
 // GET /signup
 func (u *Users) New(w http.ResponseWriter, r *http.Request) {
-	if err := u.NewView.Render(w, nil); err != nil {
+	if err := u.NewView.Render(w, r, nil); err != nil {
 		panic(err)
 	}
 }
@@ -42,6 +52,20 @@ func (u *Users) Create(w http.ResponseWriter, r *http.Request) {
 		Password: form.Password,
 	}
 	if err := u.us.Create(&user); err != nil {
+		var data views.Data
+		data.Yield = form
+		data.SetAlert(err)
+		if err := u.NewView.Render(w, r, data); err != nil {
+			panic(err)
+		}
+		return
+	}
+	verifyURL := "http://" + r.Host + "/verify?token=" + user.EmailVerifyToken
+	if err := u.emailer.VerifyEmail(user.Name, user.Email, verifyURL); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := signIn(w, u.us, &user); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -62,10 +86,7 @@ type LoginForm struct {
 	Password string `schema:"password"`
 }
 
-// Login is used to process the login form when a user
-// tries to log in as an existing user (via email & pw).
-//
-// POST /login
+// GET /login
 func (u *Users) Login(w http.ResponseWriter, r *http.Request) {
 	form := LoginForm{}
 	if err := parseForm(r, &form); err != nil {
@@ -74,12 +95,159 @@ func (u *Users) Login(w http.ResponseWriter, r *http.Request) {
 	user, err := u.us.Authenticate(form.Email, form.Password)
 	switch err {
 	case models.ErrNotFound:
-		fmt.Fprintln(w, "Invalid email address.")
+		var data views.Data
+		data.Yield = form
+		data.SetAlert(models.ValidationError{"email": "No account with that email was found."})
+		if err := u.LoginView.Render(w, r, data); err != nil {
+			panic(err)
+		}
 	case models.ErrInvalidPassword:
-		fmt.Fprintln(w, "Invalid password provided.")
+		var data views.Data
+		data.Yield = form
+		data.SetAlert(models.ValidationError{"password": "Invalid password provided."})
+		if err := u.LoginView.Render(w, r, data); err != nil {
+			panic(err)
+		}
+	case models.ErrUserDisabled:
+		var data views.Data
+		data.Yield = form
+		data.SetAlert(models.ValidationError{"email": "This account has been disabled."})
+		if err := u.LoginView.Render(w, r, data); err != nil {
+			panic(err)
+		}
 	case nil:
+		if user.TOTPEnabled {
+			setPendingLoginCookie(w, user.ID)
+			http.Redirect(w, r, "/login/2fa", http.StatusFound)
+			return
+		}
+		if err := signIn(w, u.us, user); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 		fmt.Fprintln(w, user)
 	default:
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
+
+// GET /verify
+func (u *Users) Verify(w http.ResponseWriter, r *http.Request) {
+	token := r.FormValue("token")
+	user, err := u.us.ByEmailVerifyToken(token)
+	if err != nil {
+		http.Error(w, "Invalid or expired verification link.", http.StatusBadRequest)
+		return
+	}
+	user.EmailVerification = true
+	user.EmailVerifyToken = ""
+	user.EmailVerifyTokenHash = nil
+	if err := u.us.Update(user); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := signIn(w, u.us, user); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintln(w, "Your email address has been verified.")
+}
+
+type ForgotPwForm struct {
+	Email string `schema:"email"`
+}
+
+// GET /forgot
+func (u *Users) ForgotPw(w http.ResponseWriter, r *http.Request) {
+	if err := u.ForgotPwView.Render(w, r, nil); err != nil {
+		panic(err)
+	}
+}
+
+// POST /forgot
+//
+// InitiateReset looks up the user by email and, if one exists,
+// mails them a password reset link. To avoid leaking which emails
+// are registered, this responds the same way whether or not a
+// matching user was found.
+func (u *Users) InitiateReset(w http.ResponseWriter, r *http.Request) {
+	var form ForgotPwForm
+	if err := parseForm(r, &form); err != nil {
+		panic(err)
+	}
+	user, err := u.us.ByEmail(form.Email)
+	if err != nil {
+		if err != models.ErrNotFound {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	} else {
+		pwReset := models.PasswordReset{
+			UserID: user.ID,
+		}
+		if err := u.pwResets.Create(&pwReset); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resetURL := "http://" + r.Host + "/reset?token=" + pwReset.Token
+		if err := u.emailer.ResetPw(user.Name, user.Email, resetURL); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	fmt.Fprintln(w, "Instructions to reset your password have been emailed to you.")
+}
+
+type ResetPwForm struct {
+	Token    string `schema:"token"`
+	Password string `schema:"password"`
+}
+
+// GET /reset
+func (u *Users) ResetPw(w http.ResponseWriter, r *http.Request) {
+	if err := u.ResetPwView.Render(w, r, views.Data{
+		Yield: ResetPwForm{Token: r.FormValue("token")},
+	}); err != nil {
+		panic(err)
+	}
+}
+
+// POST /reset
+//
+// CompleteReset looks up the pending PasswordReset by token,
+// updates the matching user's password, and invalidates the token
+// so it cannot be reused.
+func (u *Users) CompleteReset(w http.ResponseWriter, r *http.Request) {
+	var form ResetPwForm
+	if err := parseForm(r, &form); err != nil {
+		panic(err)
+	}
+	pwReset, err := u.pwResets.ByToken(form.Token)
+	if err != nil {
+		if err == models.ErrNotFound {
+			http.Error(w, "Invalid or expired reset link.", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	user, err := u.us.ByID(pwReset.UserID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	user.Password = form.Password
+	if err := u.us.Update(user); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := u.pwResets.Delete(pwReset.ID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := signIn(w, u.us, user); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintln(w, "Your password has been reset.")
+}
@@ -33,12 +33,13 @@ type Order struct {
 
 func main() {
 	psqlInfo := fmt.Sprintf("host=%s port=%d user=%s dbname=%s sslmode=disable", host, port, user, dbname)
-	us, err := models.NewUserService(psqlInfo)
+	services, err := models.NewServices(psqlInfo, models.DefaultPasswordHasherConfig)
 	if err != nil {
 		panic(err)
 	}
-	defer us.Close()
-	us.DestructiveReset()
+	defer services.Close()
+	services.DestructiveReset()
+	us := services.User
 	user := models.User{
 		Name:  "Michael Scott",
 		Email: "michael@dundermifflin.com",
@@ -0,0 +1,33 @@
+package hash
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// HMAC is a wrapper around the crypto/hmac package that makes
+// it a little easier to use in our application.
+type HMAC struct {
+	key []byte
+}
+
+// NewHMAC creates and returns a new HMAC object using the provided
+// secret key.
+func NewHMAC(key string) HMAC {
+	return HMAC{
+		key: []byte(key),
+	}
+}
+
+// Hash will hash the provided input string using HMAC with the
+// secret key provided when the HMAC object was created. Hash builds
+// a fresh hash.Hash on every call, since hash.Hash is stateful and
+// not safe for concurrent use, and a single HMAC is shared across
+// every request.
+func (h HMAC) Hash(input string) string {
+	mac := hmac.New(sha256.New, h.key)
+	mac.Write([]byte(input))
+	b := mac.Sum(nil)
+	return base64.URLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,12 @@
+// Package mail defines the Client interface our application uses
+// to send transactional email, along with a couple of
+// implementations of it.
+package mail
+
+// Client is used to send the transactional emails our application
+// needs to send, such as password resets and verification links.
+type Client interface {
+	Welcome(toName, toEmail string) error
+	ResetPw(toName, toEmail, resetURL string) error
+	VerifyEmail(toName, toEmail, verifyURL string) error
+}
@@ -0,0 +1,25 @@
+package mail
+
+import "log"
+
+var _ Client = &LogClient{}
+
+// LogClient is a no-op Client implementation that logs the emails
+// it would have sent instead of actually sending them. It is handy
+// for local development where no SMTP server is configured.
+type LogClient struct{}
+
+func (c *LogClient) Welcome(toName, toEmail string) error {
+	log.Printf("mail: would send welcome email to %s <%s>", toName, toEmail)
+	return nil
+}
+
+func (c *LogClient) ResetPw(toName, toEmail, resetURL string) error {
+	log.Printf("mail: would send password reset link %s to %s <%s>", resetURL, toName, toEmail)
+	return nil
+}
+
+func (c *LogClient) VerifyEmail(toName, toEmail, verifyURL string) error {
+	log.Printf("mail: would send verification link %s to %s <%s>", verifyURL, toName, toEmail)
+	return nil
+}
@@ -0,0 +1,60 @@
+package mail
+
+import (
+	"fmt"
+	"net/smtp"
+)
+
+const (
+	welcomeSubject = "Welcome to LensLocked.com!"
+	resetSubject   = "Instructions for resetting your password"
+	verifySubject  = "Please verify your email address"
+)
+
+var _ Client = &SMTPClient{}
+
+// SMTPClient sends mail via a standard SMTP server.
+type SMTPClient struct {
+	from string
+	host string
+	port int
+	auth smtp.Auth
+}
+
+// NewSMTPClient creates a SMTPClient that authenticates with the
+// provided credentials and sends mail "from" the given address.
+func NewSMTPClient(from, host string, port int, username, password string) *SMTPClient {
+	return &SMTPClient{
+		from: from,
+		host: host,
+		port: port,
+		auth: smtp.PlainAuth("", username, password, host),
+	}
+}
+
+func (c *SMTPClient) Welcome(toName, toEmail string) error {
+	return c.send(toEmail, welcomeSubject, fmt.Sprintf(
+		"Hi %s,\r\n\r\nWelcome to LensLocked.com!\r\n", toName))
+}
+
+func (c *SMTPClient) ResetPw(toName, toEmail, resetURL string) error {
+	return c.send(toEmail, resetSubject, fmt.Sprintf(
+		"Hi %s,\r\n\r\nTo reset your password, visit the following "+
+			"link:\r\n\r\n%s\r\n\r\nIf you did not request a password "+
+			"reset, you can safely ignore this email.\r\n", toName, resetURL))
+}
+
+func (c *SMTPClient) VerifyEmail(toName, toEmail, verifyURL string) error {
+	return c.send(toEmail, verifySubject, fmt.Sprintf(
+		"Hi %s,\r\n\r\nPlease verify your email address by visiting "+
+			"the following link:\r\n\r\n%s\r\n", toName, verifyURL))
+}
+
+func (c *SMTPClient) send(toEmail, subject, body string) error {
+	addr := fmt.Sprintf("%s:%d", c.host, c.port)
+	msg := []byte("From: " + c.from + "\r\n" +
+		"To: " + toEmail + "\r\n" +
+		"Subject: " + subject + "\r\n\r\n" +
+		body)
+	return smtp.SendMail(addr, c.auth, c.from, []string{toEmail}, msg)
+}
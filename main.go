@@ -1,15 +1,56 @@
 package main
 
 import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/gorilla/csrf"
 	"github.com/gorilla/mux"
 	"github.com/uhdang/lenslocked/controllers"
-	"net/http"
+	"github.com/uhdang/lenslocked/mail"
+	"github.com/uhdang/lenslocked/models"
+	"github.com/uhdang/lenslocked/oauth"
+)
+
+const (
+	host     = "localhost"
+	port     = 5432
+	user     = "postgres"
+	password = ""
+	dbname   = "lenslocked_dev"
 )
 
 func main() {
+	psqlInfo := fmt.Sprintf("host=%s port=%d user=%s "+
+		"password=%s dbname=%s sslmode=disable",
+		host, port, user, password, dbname)
+	services, err := models.NewServices(psqlInfo, models.DefaultPasswordHasherConfig)
+	if err != nil {
+		panic(err)
+	}
+	defer services.Close()
+	services.AutoMigrate()
+	if err := services.SeedAdmin(os.Getenv("ADMIN_EMAIL"), os.Getenv("ADMIN_PASSWORD")); err != nil {
+		panic(err)
+	}
+
+	emailer := &mail.LogClient{}
+
+	oauthRegistry := newOAuthRegistry()
+
 	staticC := controllers.NewStatic()
-	usersC := controllers.NewUsers()
+	usersC := controllers.NewUsers(services.User, services.PasswordReset, emailer)
 	galleriesC := controllers.NewGalleries()
+	oauthC := controllers.NewOAuth(services.User, services.OAuthConnection, oauthRegistry)
+	adminC := controllers.NewAdmin(services.User)
+	twoFactorC := controllers.NewTwoFactor(services.User)
+
+	userMw := controllers.UserMiddleware{UserService: services.User}
+	requireUserMw := controllers.RequireUser{}
+	requireUserMw.UserService = services.User
+	requireAdminMw := controllers.RequireRole{Role: models.RoleAdmin}
+	requireAdminMw.UserService = services.User
 
 	r := mux.NewRouter()
 	r.Handle("/", staticC.Home).Methods("GET")
@@ -17,7 +58,55 @@ func main() {
 	r.Handle("/faq", staticC.Faq).Methods("GET")
 	r.HandleFunc("/signup", usersC.New).Methods("GET")
 	r.HandleFunc("/signup", usersC.Create).Methods("POST")
+	r.HandleFunc("/login", usersC.LoginView.ServeHTTP).Methods("GET")
+	r.HandleFunc("/login", usersC.Login).Methods("POST")
+	r.HandleFunc("/verify", usersC.Verify).Methods("GET")
+	r.HandleFunc("/forgot", usersC.ForgotPw).Methods("GET")
+	r.HandleFunc("/forgot", usersC.InitiateReset).Methods("POST")
+	r.HandleFunc("/reset", usersC.ResetPw).Methods("GET")
+	r.HandleFunc("/reset", usersC.CompleteReset).Methods("POST")
 	r.HandleFunc("/galleries/new", galleriesC.New).Methods("GET")
+	r.HandleFunc("/oauth/{provider}/login", oauthC.Login).Methods("GET")
+	r.HandleFunc("/oauth/{provider}/callback", oauthC.Callback).Methods("GET")
+	r.HandleFunc("/admin/users", requireAdminMw.ApplyFn(adminC.List)).Methods("GET")
+	r.HandleFunc("/admin/users/{id}", requireAdminMw.ApplyFn(adminC.Edit)).Methods("GET")
+	r.HandleFunc("/admin/users/{id}", requireAdminMw.ApplyFn(adminC.Update)).Methods("POST")
+	r.HandleFunc("/admin/users/{id}/disable", requireAdminMw.ApplyFn(adminC.Disable)).Methods("POST")
+	r.HandleFunc("/admin/users/{id}", requireAdminMw.ApplyFn(adminC.Delete)).Methods("DELETE")
+	r.HandleFunc("/login/2fa", twoFactorC.LoginView.ServeHTTP).Methods("GET")
+	r.HandleFunc("/login/2fa", twoFactorC.Login).Methods("POST")
+	r.HandleFunc("/account/2fa/enroll", requireUserMw.ApplyFn(twoFactorC.Enroll)).Methods("GET")
+	r.HandleFunc("/account/2fa/enroll", requireUserMw.ApplyFn(twoFactorC.ConfirmEnroll)).Methods("POST")
+	r.HandleFunc("/account/2fa/disable", requireUserMw.ApplyFn(twoFactorC.Disable)).Methods("POST")
+
+	csrfMw := csrf.Protect(
+		[]byte("32-byte-long-auth-key-for-csrf1"),
+		csrf.Secure(false),
+	)
+
+	http.ListenAndServe(":3000", csrfMw(userMw.Apply(r)))
+}
 
-	http.ListenAndServe(":3000", r)
+// newOAuthRegistry builds the set of OAuth providers enabled for
+// this deployment. A provider is registered only when its client
+// credentials are present in the environment, so operators can
+// turn providers on or off without touching code.
+func newOAuthRegistry() *oauth.Registry {
+	registry := oauth.NewRegistry()
+	if id, secret := os.Getenv("GOOGLE_CLIENT_ID"), os.Getenv("GOOGLE_CLIENT_SECRET"); id != "" && secret != "" {
+		registry.Register(oauth.NewGoogleProvider(id, secret, os.Getenv("GOOGLE_REDIRECT_URL")))
+	}
+	if id, secret := os.Getenv("GITHUB_CLIENT_ID"), os.Getenv("GITHUB_CLIENT_SECRET"); id != "" && secret != "" {
+		registry.Register(oauth.NewGitHubProvider(id, secret, os.Getenv("GITHUB_REDIRECT_URL")))
+	}
+	if name, id, secret := os.Getenv("OIDC_PROVIDER_NAME"), os.Getenv("OIDC_CLIENT_ID"), os.Getenv("OIDC_CLIENT_SECRET"); name != "" && id != "" && secret != "" {
+		registry.Register(oauth.NewOIDCProvider(
+			name, id, secret,
+			os.Getenv("OIDC_REDIRECT_URL"),
+			os.Getenv("OIDC_AUTH_URL"),
+			os.Getenv("OIDC_TOKEN_URL"),
+			os.Getenv("OIDC_USERINFO_URL"),
+		))
+	}
+	return registry
 }
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// OAuthConnection links a User to a single identity at a
+// third-party OAuth2/OIDC provider, so a deployment can offer
+// "Sign in with..." as an alternative to email/password.
+type OAuthConnection struct {
+	gorm.Model
+	UserID          uint
+	Provider        string `gorm:"not null;unique_index:idx_oauth_provider_subject"`
+	ProviderSubject string `gorm:"not null;unique_index:idx_oauth_provider_subject"`
+	AccessToken     string
+	RefreshToken    string
+	Expiry          time.Time
+}
+
+// OAuthConnectionDB is used to interact with the oauth_connections
+// database.
+type OAuthConnectionDB interface {
+	ByProviderSubject(provider, subject string) (*OAuthConnection, error)
+	Create(conn *OAuthConnection) error
+	Update(conn *OAuthConnection) error
+}
+
+// OAuthConnectionService is a set of methods used to manipulate
+// and work with the OAuthConnection model.
+type OAuthConnectionService interface {
+	OAuthConnectionDB
+}
+
+var _ OAuthConnectionDB = &oauthConnectionGorm{}
+var _ OAuthConnectionService = &oauthConnectionValidator{}
+
+// NewOAuthConnectionService builds an OAuthConnectionService
+// backed by the provided database connection.
+func NewOAuthConnectionService(db *gorm.DB) OAuthConnectionService {
+	return &oauthConnectionValidator{
+		OAuthConnectionDB: &oauthConnectionGorm{db: db},
+	}
+}
+
+// oauthConnectionValidator is our validation layer that validates
+// data before passing it on to the next OAuthConnectionDB in our
+// interface chain.
+type oauthConnectionValidator struct {
+	OAuthConnectionDB
+}
+
+func (ocv *oauthConnectionValidator) Create(conn *OAuthConnection) error {
+	if conn.UserID <= 0 {
+		return ErrInvalidID
+	}
+	if conn.Provider == "" || conn.ProviderSubject == "" {
+		return ErrNotFound
+	}
+	return ocv.OAuthConnectionDB.Create(conn)
+}
+
+// oauthConnectionGorm represents our database interaction layer
+// and implements the OAuthConnectionDB interface fully.
+type oauthConnectionGorm struct {
+	db *gorm.DB
+}
+
+// ByProviderSubject looks up the connection, if any, for the given
+// provider and that provider's subject identifier.
+func (ocg *oauthConnectionGorm) ByProviderSubject(provider, subject string) (*OAuthConnection, error) {
+	var conn OAuthConnection
+	db := ocg.db.Where("provider = ? AND provider_subject = ?", provider, subject)
+	err := first(db, &conn)
+	if err != nil {
+		return nil, err
+	}
+	return &conn, nil
+}
+
+// Create will create the provided connection and backfill data
+// like the ID, CreatedAt, and UpdatedAt fields.
+func (ocg *oauthConnectionGorm) Create(conn *OAuthConnection) error {
+	return ocg.db.Create(conn).Error
+}
+
+// Update will update the provided connection, e.g. to persist a
+// refreshed access/refresh token pair.
+func (ocg *oauthConnectionGorm) Update(conn *OAuthConnection) error {
+	return ocg.db.Save(conn).Error
+}
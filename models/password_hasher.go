@@ -0,0 +1,269 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordPeppers is the ordered, append-only list of peppers used
+// when hashing and verifying passwords. New peppers are appended
+// to the end; the last entry is always the one used for new
+// hashes, while earlier entries remain available so hashes created
+// under an older pepper keep verifying after a rotation.
+var PasswordPeppers = []string{"random-string-as-pepper"}
+
+// PasswordAlgorithm identifies which Hasher implementation
+// produces new password hashes.
+type PasswordAlgorithm string
+
+const (
+	AlgorithmBcrypt   PasswordAlgorithm = "bcrypt"
+	AlgorithmArgon2ID PasswordAlgorithm = "argon2id"
+)
+
+// PasswordHasherConfig controls which algorithm is used for new
+// password hashes and the cost parameters for each algorithm.
+type PasswordHasherConfig struct {
+	Algorithm PasswordAlgorithm
+
+	BcryptCost int
+
+	Argon2Time    uint32
+	Argon2Memory  uint32
+	Argon2Threads uint8
+	Argon2KeyLen  uint32
+}
+
+// DefaultPasswordHasherConfig is used whenever a config isn't
+// explicitly provided, e.g. via NewUserService.
+var DefaultPasswordHasherConfig = PasswordHasherConfig{
+	Algorithm:     AlgorithmArgon2ID,
+	BcryptCost:    bcrypt.DefaultCost,
+	Argon2Time:    3,
+	Argon2Memory:  64 * 1024,
+	Argon2Threads: 2,
+	Argon2KeyLen:  32,
+}
+
+// Hasher hashes and verifies passwords. Implementations encode
+// enough of their own algorithm and parameters into the hash string
+// that a later Compare call can self-describe how to verify it,
+// even after the default algorithm or parameters have since changed.
+type Hasher interface {
+	// Hash hashes password using the current pepper and returns a
+	// self-describing, prefixed hash safe to store in PasswordHash.
+	Hash(password string) (string, error)
+
+	// Compare reports whether password matches the provided hash.
+	// It returns bcrypt.ErrMismatchedHashAndPassword on mismatch so
+	// callers can keep checking against that sentinel regardless of
+	// which algorithm produced the hash.
+	Compare(hash, password string) error
+
+	// NeedsRehash reports whether hash was produced by a weaker
+	// algorithm or parameters than this Hasher's current config, and
+	// so should be replaced the next time the password is verified.
+	NeedsRehash(hash string) bool
+}
+
+var errUnknownHashFormat = errors.New("models: unrecognized password hash format")
+
+// NewHasher builds the Hasher used for new passwords and for
+// verifying passwords created under any algorithm this package
+// supports, using cfg and the current PasswordPeppers.
+func NewHasher(cfg PasswordHasherConfig) Hasher {
+	return &multiHasher{
+		cfg:     cfg,
+		peppers: PasswordPeppers,
+	}
+}
+
+// multiHasher hashes new passwords with cfg.Algorithm but can
+// Compare a hash produced by any registered algorithm, dispatching
+// on the algorithm name encoded in the hash's prefix.
+type multiHasher struct {
+	cfg     PasswordHasherConfig
+	peppers []string
+}
+
+func (m *multiHasher) pepper(version int) (string, error) {
+	if version < 0 || version >= len(m.peppers) {
+		return "", errors.New("models: unknown pepper version")
+	}
+	return m.peppers[version], nil
+}
+
+func (m *multiHasher) currentPepperVersion() int {
+	return len(m.peppers) - 1
+}
+
+func (m *multiHasher) Hash(password string) (string, error) {
+	switch m.cfg.Algorithm {
+	case AlgorithmBcrypt:
+		return m.hashBcrypt(password)
+	default:
+		return m.hashArgon2ID(password)
+	}
+}
+
+func (m *multiHasher) hashBcrypt(password string) (string, error) {
+	pv := m.currentPepperVersion()
+	pepper, err := m.pepper(pv)
+	if err != nil {
+		return "", err
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password+pepper), m.cfg.BcryptCost)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("$bcrypt$pv=%d$%s", pv, hashed), nil
+}
+
+func (m *multiHasher) hashArgon2ID(password string) (string, error) {
+	pv := m.currentPepperVersion()
+	pepper, err := m.pepper(pv)
+	if err != nil {
+		return "", err
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password+pepper), salt,
+		m.cfg.Argon2Time, m.cfg.Argon2Memory, m.cfg.Argon2Threads, m.cfg.Argon2KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$pv=%d$%s$%s",
+		argon2.Version, m.cfg.Argon2Memory, m.cfg.Argon2Time, m.cfg.Argon2Threads, pv,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (m *multiHasher) Compare(hash, password string) error {
+	switch {
+	case strings.HasPrefix(hash, "$bcrypt$"):
+		return m.compareBcrypt(hash, password)
+	case strings.HasPrefix(hash, "$argon2id$"):
+		return m.compareArgon2ID(hash, password)
+	default:
+		return errUnknownHashFormat
+	}
+}
+
+func (m *multiHasher) compareBcrypt(hash, password string) error {
+	parts := strings.SplitN(hash, "$", 4)
+	if len(parts) != 4 {
+		return errUnknownHashFormat
+	}
+	pv, err := parsePepperVersion(parts[2])
+	if err != nil {
+		return err
+	}
+	pepper, err := m.pepper(pv)
+	if err != nil {
+		return err
+	}
+	return bcrypt.CompareHashAndPassword([]byte(parts[3]), []byte(password+pepper))
+}
+
+func (m *multiHasher) compareArgon2ID(hash, password string) error {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 7 {
+		return errUnknownHashFormat
+	}
+	var version int
+	var memory, time uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return errUnknownHashFormat
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return errUnknownHashFormat
+	}
+	pv, err := parsePepperVersion(parts[4])
+	if err != nil {
+		return err
+	}
+	pepper, err := m.pepper(pv)
+	if err != nil {
+		return err
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return errUnknownHashFormat
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[6])
+	if err != nil {
+		return errUnknownHashFormat
+	}
+	got := argon2.IDKey([]byte(password+pepper), salt, time, memory, threads, uint32(len(want)))
+	if len(got) != len(want) || !constantTimeEqual(got, want) {
+		return bcrypt.ErrMismatchedHashAndPassword
+	}
+	return nil
+}
+
+func (m *multiHasher) NeedsRehash(hash string) bool {
+	switch {
+	case strings.HasPrefix(hash, "$bcrypt$"):
+		if m.cfg.Algorithm != AlgorithmBcrypt {
+			return true
+		}
+		if !strings.Contains(hash, fmt.Sprintf("$pv=%d$", m.currentPepperVersion())) {
+			return true
+		}
+		parts := strings.SplitN(hash, "$", 4)
+		if len(parts) != 4 {
+			return true
+		}
+		cost, err := bcrypt.Cost([]byte(parts[3]))
+		if err != nil {
+			return true
+		}
+		return cost < m.cfg.BcryptCost
+	case strings.HasPrefix(hash, "$argon2id$"):
+		if m.cfg.Algorithm != AlgorithmArgon2ID {
+			return true
+		}
+		parts := strings.Split(hash, "$")
+		if len(parts) != 7 {
+			return true
+		}
+		var memory, time uint32
+		var threads uint8
+		if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+			return true
+		}
+		pv, err := parsePepperVersion(parts[4])
+		if err != nil {
+			return true
+		}
+		return memory < m.cfg.Argon2Memory || time < m.cfg.Argon2Time ||
+			threads < m.cfg.Argon2Threads || pv != m.currentPepperVersion()
+	default:
+		return true
+	}
+}
+
+func parsePepperVersion(field string) (int, error) {
+	var pv int
+	if _, err := fmt.Sscanf(field, "pv=%d", &pv); err != nil {
+		return 0, errUnknownHashFormat
+	}
+	return pv, nil
+}
+
+// constantTimeEqual compares two equal-length byte slices in
+// constant time to avoid leaking timing information about how many
+// leading bytes matched.
+func constantTimeEqual(a, b []byte) bool {
+	var v byte
+	for i := range a {
+		v |= a[i] ^ b[i]
+	}
+	return v == 0
+}
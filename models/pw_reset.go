@@ -0,0 +1,124 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/uhdang/lenslocked/hash"
+	"github.com/uhdang/lenslocked/rand"
+)
+
+// PasswordResetDuration is how long a password reset token remains
+// valid for after it is issued.
+var PasswordResetDuration = time.Hour
+
+// PasswordReset represents a single-use, time-bounded request to
+// reset the password for UserID.
+type PasswordReset struct {
+	gorm.Model
+	UserID    uint
+	Token     string `gorm:"-"`
+	TokenHash string `gorm:"not null;unique_index"`
+	ExpiresAt time.Time
+}
+
+// PasswordResetDB is used to interact with the password_resets
+// database.
+type PasswordResetDB interface {
+	ByToken(token string) (*PasswordReset, error)
+	Create(pwReset *PasswordReset) error
+	Delete(id uint) error
+}
+
+// PasswordResetService is a set of methods used to manipulate and
+// work with the PasswordReset model.
+type PasswordResetService interface {
+	PasswordResetDB
+}
+
+var _ PasswordResetDB = &pwResetGorm{}
+var _ PasswordResetService = &pwResetValidator{}
+
+// NewPasswordResetService builds a PasswordResetService backed by
+// the provided database connection.
+func NewPasswordResetService(db *gorm.DB) PasswordResetService {
+	prg := &pwResetGorm{db: db}
+	hmac := hash.NewHMAC(hmacSecretKey)
+	return &pwResetValidator{
+		PasswordResetDB: prg,
+		hmac:            hmac,
+	}
+}
+
+// pwResetValidator is our validation layer that validates and
+// normalizes data before passing it on to the next
+// PasswordResetDB in our interface chain.
+type pwResetValidator struct {
+	PasswordResetDB
+	hmac hash.HMAC
+}
+
+// Create generates a random token for UserID, hashes it, and
+// stores it with an expiry of PasswordResetDuration from now. The
+// plaintext token is left on pwReset.Token so the caller can mail
+// it to the user; only the hash is ever persisted.
+func (prv *pwResetValidator) Create(pwReset *PasswordReset) error {
+	if pwReset.UserID <= 0 {
+		return ErrInvalidID
+	}
+	token, err := rand.RememberToken()
+	if err != nil {
+		return err
+	}
+	pwReset.Token = token
+	pwReset.TokenHash = prv.hmac.Hash(pwReset.Token)
+	pwReset.ExpiresAt = time.Now().Add(PasswordResetDuration)
+	return prv.PasswordResetDB.Create(pwReset)
+}
+
+// ByToken hashes the provided token and looks up the matching
+// PasswordReset. It returns ErrNotFound if the token is unknown
+// or has already expired.
+func (prv *pwResetValidator) ByToken(token string) (*PasswordReset, error) {
+	if token == "" {
+		return nil, ErrNotFound
+	}
+	tokenHash := prv.hmac.Hash(token)
+	pwReset, err := prv.PasswordResetDB.ByToken(tokenHash)
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().After(pwReset.ExpiresAt) {
+		return nil, ErrNotFound
+	}
+	return pwReset, nil
+}
+
+// pwResetGorm represents our database interaction layer and
+// implements the PasswordResetDB interface fully.
+type pwResetGorm struct {
+	db *gorm.DB
+}
+
+// ByToken looks up a PasswordReset by the (already hashed) token.
+func (prg *pwResetGorm) ByToken(tokenHash string) (*PasswordReset, error) {
+	var pwReset PasswordReset
+	err := first(prg.db.Where("token_hash = ?", tokenHash), &pwReset)
+	if err != nil {
+		return nil, err
+	}
+	return &pwReset, nil
+}
+
+// Create will create the provided PasswordReset and backfill data
+// like the ID, CreatedAt, and UpdatedAt fields.
+func (prg *pwResetGorm) Create(pwReset *PasswordReset) error {
+	return prg.db.Create(pwReset).Error
+}
+
+// Delete removes the PasswordReset with the provided ID, invalidating
+// it so it cannot be used again.
+func (prg *pwResetGorm) Delete(id uint) error {
+	pwReset := PasswordReset{Model: gorm.Model{ID: id}}
+	return prg.db.Delete(&pwReset).Error
+}
@@ -0,0 +1,150 @@
+package models
+
+import (
+	"github.com/jinzhu/gorm"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/uhdang/lenslocked/rand"
+)
+
+// RecoveryCodeCount is how many single-use recovery codes are
+// (re)generated whenever a user enables 2FA or asks for a fresh set.
+const RecoveryCodeCount = 10
+
+// RecoveryCode is a single-use code that can be exchanged for a
+// valid 2FA step when a user has lost access to their TOTP app. Only
+// a bcrypt hash of the code is ever persisted.
+type RecoveryCode struct {
+	gorm.Model
+	UserID   uint   `gorm:"not null;index"`
+	CodeHash string `gorm:"not null"`
+	Used     bool
+}
+
+// RecoveryCodeDB is used to interact with the recovery_codes
+// database.
+type RecoveryCodeDB interface {
+	ByUserID(userID uint) ([]RecoveryCode, error)
+	Create(code *RecoveryCode) error
+	Update(code *RecoveryCode) error
+	DeleteByUserID(userID uint) error
+}
+
+// RecoveryCodeService is a set of methods used to manipulate and
+// work with the RecoveryCode model.
+type RecoveryCodeService interface {
+	// Generate replaces every existing recovery code for userID with
+	// RecoveryCodeCount freshly generated ones, and returns their
+	// plaintext so the caller can show them to the user exactly
+	// once.
+	Generate(userID uint) ([]string, error)
+
+	// Consume looks up userID's unused recovery codes, and if code
+	// matches one, marks it used and returns nil. It returns
+	// ErrNotFound if no unused code matches.
+	Consume(userID uint, code string) error
+
+	RecoveryCodeDB
+}
+
+var _ RecoveryCodeDB = &recoveryCodeGorm{}
+var _ RecoveryCodeService = &recoveryCodeValidator{}
+
+// NewRecoveryCodeService builds a RecoveryCodeService backed by the
+// provided database connection.
+func NewRecoveryCodeService(db *gorm.DB) RecoveryCodeService {
+	return &recoveryCodeValidator{
+		RecoveryCodeDB: &recoveryCodeGorm{db: db},
+	}
+}
+
+// recoveryCodeValidator is our validation layer that validates and
+// normalizes data before passing it on to the next RecoveryCodeDB in
+// our interface chain.
+type recoveryCodeValidator struct {
+	RecoveryCodeDB
+}
+
+// Generate deletes userID's existing recovery codes and creates
+// RecoveryCodeCount new ones, returning their plaintext.
+func (rcv *recoveryCodeValidator) Generate(userID uint) ([]string, error) {
+	if userID == 0 {
+		return nil, ErrInvalidID
+	}
+	if err := rcv.DeleteByUserID(userID); err != nil {
+		return nil, err
+	}
+	codes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		code, err := rand.RecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		hashed, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		if err := rcv.RecoveryCodeDB.Create(&RecoveryCode{
+			UserID:   userID,
+			CodeHash: string(hashed),
+		}); err != nil {
+			return nil, err
+		}
+		codes[i] = code
+	}
+	return codes, nil
+}
+
+// Consume reports ErrNotFound if code doesn't match any of userID's
+// unused recovery codes; otherwise it marks the matching code used
+// so it cannot be reused.
+func (rcv *recoveryCodeValidator) Consume(userID uint, code string) error {
+	codes, err := rcv.ByUserID(userID)
+	if err != nil {
+		return err
+	}
+	for _, rc := range codes {
+		if rc.Used {
+			continue
+		}
+		if bcrypt.CompareHashAndPassword([]byte(rc.CodeHash), []byte(code)) == nil {
+			rc.Used = true
+			return rcv.RecoveryCodeDB.Update(&rc)
+		}
+	}
+	return ErrNotFound
+}
+
+// recoveryCodeGorm represents our database interaction layer and
+// implements the RecoveryCodeDB interface fully.
+type recoveryCodeGorm struct {
+	db *gorm.DB
+}
+
+// ByUserID returns every recovery code, used or not, belonging to
+// userID.
+func (rcg *recoveryCodeGorm) ByUserID(userID uint) ([]RecoveryCode, error) {
+	var codes []RecoveryCode
+	err := rcg.db.Where("user_id = ?", userID).Find(&codes).Error
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Create will create the provided RecoveryCode and backfill data
+// like the ID, CreatedAt, and UpdatedAt fields.
+func (rcg *recoveryCodeGorm) Create(code *RecoveryCode) error {
+	return rcg.db.Create(code).Error
+}
+
+// Update will update the provided RecoveryCode with all data in the
+// provided RecoveryCode object.
+func (rcg *recoveryCodeGorm) Update(code *RecoveryCode) error {
+	return rcg.db.Save(code).Error
+}
+
+// DeleteByUserID removes every recovery code belonging to userID.
+func (rcg *recoveryCodeGorm) DeleteByUserID(userID uint) error {
+	return rcg.db.Where("user_id = ?", userID).Delete(&RecoveryCode{}).Error
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/postgres"
+)
+
+// Services is the single point of access for every model service,
+// sharing one underlying database connection.
+type Services struct {
+	User            UserService
+	PasswordReset   PasswordResetService
+	OAuthConnection OAuthConnectionService
+	db              *gorm.DB
+}
+
+// NewServices opens a connection to the database described by
+// connectionInfo and wires up every model service on top of it,
+// hashing passwords according to hasherCfg.
+func NewServices(connectionInfo string, hasherCfg PasswordHasherConfig) (*Services, error) {
+	db, err := gorm.Open("postgres", connectionInfo)
+	if err != nil {
+		return nil, err
+	}
+	db.LogMode(true)
+	return &Services{
+		User:            NewUserServiceWithHasher(db, NewHasher(hasherCfg)),
+		PasswordReset:   NewPasswordResetService(db),
+		OAuthConnection: NewOAuthConnectionService(db),
+		db:              db,
+	}, nil
+}
+
+// Close closes the underlying database connection.
+func (s *Services) Close() error {
+	return s.db.Close()
+}
+
+// AutoMigrate will attempt to automatically migrate every table
+// our model services need.
+func (s *Services) AutoMigrate() error {
+	return s.db.AutoMigrate(&User{}, &PasswordReset{}, &OAuthConnection{}, &RecoveryCode{}).Error
+}
+
+// DestructiveReset drops every table our model services use and
+// rebuilds them via AutoMigrate.
+func (s *Services) DestructiveReset() error {
+	err := s.db.DropTableIfExists(&User{}, &PasswordReset{}, &OAuthConnection{}, &RecoveryCode{}).Error
+	if err != nil {
+		return err
+	}
+	return s.AutoMigrate()
+}
+
+// SeedAdmin ensures an admin user exists with the given email and
+// password, creating one if no user with that email is found yet.
+// It is a no-op if the user already exists, so it is safe to call
+// on every startup.
+func (s *Services) SeedAdmin(email, password string) error {
+	if email == "" || password == "" {
+		return nil
+	}
+	_, err := s.User.ByEmail(email)
+	if err == nil {
+		return nil
+	}
+	if err != ErrNotFound {
+		return err
+	}
+	admin := User{
+		Name:     "Admin",
+		Email:    email,
+		Password: password,
+		Role:     RoleAdmin,
+	}
+	return s.User.Create(&admin)
+}
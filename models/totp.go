@@ -0,0 +1,103 @@
+package models
+
+import (
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+// totpPeriod and totpSkew configure the validity window for a TOTP
+// code: a code is accepted if it matches the current period or
+// either adjacent one.
+const (
+	totpPeriod = 30
+	totpSkew   = 1
+)
+
+// BeginTOTPEnrollment generates a new TOTP secret for user, encrypts
+// it at rest on user.TOTPSecret, and returns the plaintext secret
+// along with an otpauth:// URL for a QR code. TOTPEnabled is left
+// false until ConfirmTOTPEnrollment validates a code against it.
+func (us *userService) BeginTOTPEnrollment(user *User, issuer string) (string, string, error) {
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      issuer,
+		AccountName: user.Email,
+		Period:      totpPeriod,
+	})
+	if err != nil {
+		return "", "", err
+	}
+	encrypted, err := encryptTOTPSecret(key.Secret())
+	if err != nil {
+		return "", "", err
+	}
+	user.TOTPSecret = encrypted
+	user.TOTPEnabled = false
+	if err := us.Update(user); err != nil {
+		return "", "", err
+	}
+	return key.Secret(), key.URL(), nil
+}
+
+// ConfirmTOTPEnrollment validates code against the secret
+// BeginTOTPEnrollment stored on user. On success it generates a
+// fresh set of recovery codes and only then enables 2FA, so a
+// failure to generate codes never leaves 2FA enabled with none.
+func (us *userService) ConfirmTOTPEnrollment(user *User, code string) ([]string, error) {
+	secret, err := decryptTOTPSecret(user.TOTPSecret)
+	if err != nil {
+		return nil, err
+	}
+	if !validTOTPCode(secret, code) {
+		return nil, ErrInvalidTOTPCode
+	}
+	recoveryCodes, err := us.recoveryCodes.Generate(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	user.TOTPEnabled = true
+	if err := us.Update(user); err != nil {
+		return nil, err
+	}
+	return recoveryCodes, nil
+}
+
+// DisableTOTP turns 2FA off for user and removes its secret and
+// recovery codes.
+func (us *userService) DisableTOTP(user *User) error {
+	user.TOTPSecret = ""
+	user.TOTPEnabled = false
+	if err := us.Update(user); err != nil {
+		return err
+	}
+	return us.recoveryCodes.DeleteByUserID(user.ID)
+}
+
+// ValidateTOTP reports whether code is a valid TOTP code for user's
+// enrolled secret, or, failing that, an unused recovery code, which
+// is consumed if it matches.
+func (us *userService) ValidateTOTP(user *User, code string) error {
+	if user.TOTPSecret != "" {
+		secret, err := decryptTOTPSecret(user.TOTPSecret)
+		if err == nil && validTOTPCode(secret, code) {
+			return nil
+		}
+	}
+	if err := us.recoveryCodes.Consume(user.ID, code); err == nil {
+		return nil
+	}
+	return ErrInvalidTOTPCode
+}
+
+// validTOTPCode reports whether code is valid for secret at the
+// current time, within totpSkew adjacent periods.
+func validTOTPCode(secret, code string) bool {
+	valid, _ := totp.ValidateCustom(code, secret, time.Now(), totp.ValidateOpts{
+		Period:    totpPeriod,
+		Skew:      totpSkew,
+		Digits:    otp.DigitsSix,
+		Algorithm: otp.AlgorithmSHA1,
+	})
+	return valid
+}
@@ -1,7 +1,12 @@
 package models
 
 import (
+	"encoding/base64"
 	"errors"
+	"regexp"
+	"strings"
+	"time"
+
 	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/gorm/dialects/postgres"
 	"github.com/uhdang/lenslocked/hash"
@@ -9,6 +14,15 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// EmailVerificationDuration is how long an email verification link
+// remains valid for after it is issued.
+var EmailVerificationDuration = 24 * time.Hour
+
+// emailFormatRegex is a deliberately permissive check for
+// "looks like an email address" rather than a full RFC 5322
+// validator.
+var emailFormatRegex = regexp.MustCompile(`^[a-zA-Z0-9.+_-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
+
 var (
 	// ErrNotFound is returned when a resource cannot be found // in the database.
 	ErrNotFound = errors.New("models: resource not found")
@@ -20,7 +34,13 @@ var (
 	//is used when attempting to authenticate a user.
 	ErrInvalidPassword = errors.New("models: incorrect password provided")
 
-	userPwPepper = "random-string-as-pepper"
+	// ErrUserDisabled is returned when attempting to authenticate a
+	// user an admin has disabled.
+	ErrUserDisabled = errors.New("models: user account is disabled")
+
+	// ErrInvalidTOTPCode is returned when a 2FA code or recovery
+	// code fails to validate.
+	ErrInvalidTOTPCode = errors.New("models: invalid 2FA code")
 )
 
 var _ UserDB = &userGorm{}
@@ -28,15 +48,31 @@ var _ UserService = &userService{}
 
 const hmacSecretKey = "secret-hmac-key"
 
+const (
+	// RoleUser is the default Role for newly created users.
+	RoleUser = "user"
+	// RoleAdmin grants access to the admin subsystem, e.g. via
+	// RequireRole(RoleAdmin) in controllers.
+	RoleAdmin = "admin"
+)
+
 type User struct {
 	gorm.Model
-	Name         string
-	Email        string `gorm:"not null;unique_index"`
-	Password     string `gorm:"-"`
-	PasswordHash string `gorm:"not null"`
-	Remember     string `gorm:"-"`
-	RememberHash string `gorm:"not null;unique_index"`
-	Age          int
+	Name                    string
+	Email                   string `gorm:"not null;unique_index"`
+	Password                string `gorm:"-"`
+	PasswordHash            string `gorm:"not null"`
+	Remember                string `gorm:"-"`
+	RememberHash            string `gorm:"not null;unique_index"`
+	Age                     int
+	Role                    string `gorm:"not null"`
+	Disabled                bool
+	EmailVerification       bool
+	EmailVerifyToken        string  `gorm:"-"`
+	EmailVerifyTokenHash    *string `gorm:"unique_index"`
+	EmailVerifyTokenExpires time.Time
+	TOTPSecret              string
+	TOTPEnabled             bool
 }
 
 // UserService is a set of methods used to manipulate and
@@ -49,11 +85,35 @@ type UserService interface {
 	// ErrNotFound, ErrInvalidPassword, or another error if
 	// something goes wrong
 	Authenticate(email, password string) (*User, error)
+
+	// BeginTOTPEnrollment generates a new TOTP secret for user,
+	// persisting it encrypted without enabling 2FA yet, and returns
+	// the plaintext secret plus an otpauth:// URL suitable for a QR
+	// code.
+	BeginTOTPEnrollment(user *User, issuer string) (secret, otpauthURL string, err error)
+
+	// ConfirmTOTPEnrollment validates code against the pending TOTP
+	// secret BeginTOTPEnrollment stored on user. If it matches, 2FA
+	// is enabled and a fresh set of recovery codes is generated and
+	// returned in plaintext.
+	ConfirmTOTPEnrollment(user *User, code string) (recoveryCodes []string, err error)
+
+	// DisableTOTP turns 2FA off for user, clearing its secret and
+	// every recovery code.
+	DisableTOTP(user *User) error
+
+	// ValidateTOTP reports whether code is a valid TOTP code or an
+	// unused recovery code for user, consuming the recovery code if
+	// that's what matched.
+	ValidateTOTP(user *User, code string) error
+
 	UserDB
 }
 
 type userService struct {
 	UserDB
+	hasher        Hasher
+	recoveryCodes RecoveryCodeService
 }
 
 // UserDB is used to interact with the user database.
@@ -62,18 +122,17 @@ type UserDB interface {
 	ByID(id uint) (*User, error)
 	ByEmail(email string) (*User, error)
 	ByRemember(token string) (*User, error)
+	ByEmailVerifyToken(token string) (*User, error)
+
+	// Search returns the page'th page (1-indexed) of users whose
+	// name or email contains query, along with the total number of
+	// matching users, so callers can build pagination headers.
+	Search(query string, page, pageSize int) ([]User, int, error)
 
 	// Methods for altering users
 	Create(user *User) error
 	Update(user *User) error
 	Delete(id uint) error
-
-	// Used to close a DB connection
-	Close() error
-
-	// Migration helpers
-	AutoMigrate() error
-	DestructiveReset() error
 }
 
 //func (uv *userValidator) ByID(id uint) (*User, error) {
@@ -87,26 +146,35 @@ type UserDB interface {
 //}
 
 // Authenticate can be used to authenticate a user with the
-//provided email address and password.
+// provided email address and password.
 // If the email address provided is invalid, this will return
-//		nil, ErrNotFound
+//
+//	nil, ErrNotFound
+//
 // If the password provided is invalid, this will return
-//		nil, ErrInvalidPassword
+//
+//	nil, ErrInvalidPassword
+//
 // If the email and password are both valid, this will return
-//		user, nil
+//
+//	user, nil
+//
 // Otherwise if another error is encountered this will return
-//		nil, error
+//
+//	nil, error
 func (us *userService) Authenticate(email, password string) (*User, error) {
 	foundUser, err := us.ByEmail(email)
 	if err != nil {
 		return nil, err
 	}
+	if foundUser.Disabled {
+		return nil, ErrUserDisabled
+	}
 
-	err = bcrypt.CompareHashAndPassword(
-		[]byte(foundUser.PasswordHash),
-		[]byte(password+userPwPepper))
+	err = us.hasher.Compare(foundUser.PasswordHash, password)
 	switch err {
 	case nil:
+		us.rehashIfNeeded(foundUser, password)
 		return foundUser, nil
 	case bcrypt.ErrMismatchedHashAndPassword:
 		return nil, ErrInvalidPassword
@@ -115,19 +183,43 @@ func (us *userService) Authenticate(email, password string) (*User, error) {
 	}
 }
 
-func NewUserService(connectionInfo string) (UserService, error) {
-	ug, err := newUserGorm(connectionInfo)
-	if err != nil {
-		return nil, err
+// rehashIfNeeded transparently upgrades foundUser's PasswordHash
+// when it was produced by a weaker algorithm or parameters than
+// us.hasher's current config. Failures are ignored: the user is
+// still successfully authenticated and will simply be re-hashed on
+// a future login.
+func (us *userService) rehashIfNeeded(foundUser *User, password string) {
+	if !us.hasher.NeedsRehash(foundUser.PasswordHash) {
+		return
 	}
+	foundUser.Password = password
+	_ = us.Update(foundUser)
+}
+
+// NewUserService builds a UserService backed by the provided
+// database connection, hashing and verifying passwords with
+// DefaultPasswordHasherConfig. Use NewUserServiceWithHasher to
+// provide a custom Hasher, e.g. to change the algorithm or cost.
+func NewUserService(db *gorm.DB) UserService {
+	return NewUserServiceWithHasher(db, NewHasher(DefaultPasswordHasherConfig))
+}
+
+// NewUserServiceWithHasher is the same as NewUserService except it
+// lets the caller choose the Hasher used for new and existing
+// passwords, e.g. to tune argon2id cost or rotate peppers.
+func NewUserServiceWithHasher(db *gorm.DB, hasher Hasher) UserService {
+	ug := &userGorm{db: db}
 	hmac := hash.NewHMAC(hmacSecretKey)
 	uv := &userValidator{
 		hmac:   hmac,
+		hasher: hasher,
 		UserDB: ug,
 	}
 	return &userService{
-		UserDB: uv,
-	}, nil
+		UserDB:        uv,
+		hasher:        hasher,
+		recoveryCodes: NewRecoveryCodeService(db),
+	}
 }
 
 // userValidator is our validation layer that validates
@@ -135,39 +227,126 @@ func NewUserService(connectionInfo string) (UserService, error) {
 // UserDB in our interface chain.
 type userValidator struct {
 	UserDB
-	hmac hash.HMAC
+	hmac   hash.HMAC
+	hasher Hasher
 }
 
 // userValFn defines a single format for validation functions
 type userValFn func(*User) error
 
+// runUserValFns runs every fn against user. A *FieldError is
+// collected into the ValidationError returned at the end, so every
+// field-level problem is reported at once; any other error aborts
+// immediately and is returned as-is, since it indicates something
+// went wrong rather than that the input was invalid.
 func runUserValFns(user *User, fns ...userValFn) error {
+	validation := ValidationError{}
 	for _, fn := range fns {
-		if err := fn(user); err != nil {
-			return err
+		err := fn(user)
+		if err == nil {
+			continue
+		}
+		if fe, ok := err.(*FieldError); ok {
+			validation[fe.Field] = fe.Message
+			continue
 		}
+		return err
+	}
+	if len(validation) > 0 {
+		return validation
 	}
 	return nil
 }
 
-// bcryptPassword will hash a user's password with an
-// app-wide pepper and bcrypt, which salts for us.
-func (uv *userValidator) bcryptPassword(user *User) error {
+// hashPassword will hash a user's password with the configured
+// Hasher, which applies the current pepper for us.
+func (uv *userValidator) hashPassword(user *User) error {
 	if user.Password == "" {
 		// We DO NOT need to run this if the password
 		// hasn't been changed
 		return nil
 	}
-	pwBytes := []byte(user.Password + userPwPepper)
-	hashedBytes, err := bcrypt.GenerateFromPassword(pwBytes, bcrypt.DefaultCost)
+	hashed, err := uv.hasher.Hash(user.Password)
 	if err != nil {
 		return err
 	}
-	user.PasswordHash = string(hashedBytes)
+	user.PasswordHash = hashed
 	user.Password = ""
 	return nil
 }
 
+// passwordMinLength requires a newly supplied password be at least
+// 8 characters long. It is a no-op if the password isn't changing.
+func (uv *userValidator) passwordMinLength(user *User) error {
+	if user.Password == "" {
+		return nil
+	}
+	if len(user.Password) < 8 {
+		return &FieldError{Field: "password", Message: "must be at least 8 characters long"}
+	}
+	return nil
+}
+
+// passwordHashRequired ensures every user ends up with a password
+// hash, whether they just set a new password or already had one.
+func (uv *userValidator) passwordHashRequired(user *User) error {
+	if user.PasswordHash == "" {
+		return &FieldError{Field: "password", Message: "is required"}
+	}
+	return nil
+}
+
+// emailNormalize lowercases and trims whitespace from the user's
+// email so "Foo@Bar.com " and "foo@bar.com" are treated the same.
+func (uv *userValidator) emailNormalize(user *User) error {
+	user.Email = strings.ToLower(strings.TrimSpace(user.Email))
+	return nil
+}
+
+// emailFormat requires the email look like a valid email address.
+func (uv *userValidator) emailFormat(user *User) error {
+	if user.Email == "" {
+		return &FieldError{Field: "email", Message: "is required"}
+	}
+	if !emailFormatRegex.MatchString(user.Email) {
+		return &FieldError{Field: "email", Message: "is not a valid email address"}
+	}
+	return nil
+}
+
+// emailIsAvail requires the email not already belong to a
+// different user.
+func (uv *userValidator) emailIsAvail(user *User) error {
+	existing, err := uv.ByEmail(user.Email)
+	if err == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if user.ID != existing.ID {
+		return &FieldError{Field: "email", Message: "is already taken"}
+	}
+	return nil
+}
+
+// rememberMinBytes requires the (decoded) remember token be at
+// least rand.RememberTokenBytes long, the same size we generate in
+// setRememberIfUnset.
+func (uv *userValidator) rememberMinBytes(user *User) error {
+	if user.Remember == "" {
+		return nil
+	}
+	n, err := base64.URLEncoding.DecodeString(user.Remember)
+	if err != nil {
+		return &FieldError{Field: "remember", Message: "is not valid"}
+	}
+	if len(n) < rand.RememberTokenBytes {
+		return &FieldError{Field: "remember", Message: "is not long enough"}
+	}
+	return nil
+}
+
 // hmacRemember we check to see if the remember token was set before proceeding.
 // If it was not set, we terminate early returning nil. Otherwise we hash the
 // remember token that is present
@@ -193,6 +372,45 @@ func (uv *userValidator) setRememberIfUnset(user *User) error {
 	return nil
 }
 
+// hmacEmailVerifyToken hashes the pending email verification token,
+// if one is set, the same way hmacRemember hashes a remember token.
+// EmailVerifyTokenHash is a *string, not a plain string, so that a
+// cleared (nil) token never collides with every other cleared
+// token under the column's unique index.
+func (uv *userValidator) hmacEmailVerifyToken(user *User) error {
+	if user.EmailVerifyToken == "" {
+		return nil
+	}
+	hashed := uv.hmac.Hash(user.EmailVerifyToken)
+	user.EmailVerifyTokenHash = &hashed
+	return nil
+}
+
+// setEmailVerifyTokenIfUnset generates a new email verification
+// token, good for EmailVerificationDuration, for newly created users
+// so a verification link can be mailed to them.
+func (uv *userValidator) setEmailVerifyTokenIfUnset(user *User) error {
+	if user.EmailVerifyToken != "" {
+		return nil
+	}
+	token, err := rand.RememberToken()
+	if err != nil {
+		return err
+	}
+	user.EmailVerifyToken = token
+	user.EmailVerifyTokenExpires = time.Now().Add(EmailVerificationDuration)
+	return nil
+}
+
+// roleDefault assigns RoleUser to a newly created user that doesn't
+// already have a Role set, e.g. the seeded first admin.
+func (uv *userValidator) roleDefault(user *User) error {
+	if user.Role == "" {
+		user.Role = RoleUser
+	}
+	return nil
+}
+
 func (uv *userValidator) idGreaterThan(n uint) userValFn {
 	return userValFn(func(user *User) error {
 		if user.ID <= n {
@@ -203,7 +421,10 @@ func (uv *userValidator) idGreaterThan(n uint) userValFn {
 }
 
 // ByRemember will hash the remember token and then call
-// ByRemember on the subsequent UserDB layer.
+// ByRemember on the subsequent UserDB layer. It returns ErrNotFound
+// for a disabled user, since a disabled account's existing sessions
+// must stop working immediately rather than waiting for the
+// remember_token cookie to expire.
 func (uv *userValidator) ByRemember(token string) (*User, error) {
 	user := User{
 		Remember: token,
@@ -211,16 +432,32 @@ func (uv *userValidator) ByRemember(token string) (*User, error) {
 	if err := runUserValFns(&user, uv.hmacRemember); err != nil {
 		return nil, err
 	}
-	return uv.UserDB.ByRemember(user.RememberHash)
+	foundUser, err := uv.UserDB.ByRemember(user.RememberHash)
+	if err != nil {
+		return nil, err
+	}
+	if foundUser.Disabled {
+		return nil, ErrNotFound
+	}
+	return foundUser, nil
 }
 
 // Create will create the provided user and backfill data
 // lke the ID, CreateAt, and UpdatedAt fields.
 func (uv *userValidator) Create(user *User) error {
 	err := runUserValFns(user,
-		uv.bcryptPassword,
+		uv.emailNormalize,
+		uv.emailFormat,
+		uv.emailIsAvail,
+		uv.passwordMinLength,
+		uv.hashPassword,
+		uv.passwordHashRequired,
+		uv.roleDefault,
 		uv.setRememberIfUnset,
 		uv.hmacRemember,
+		uv.rememberMinBytes,
+		uv.setEmailVerifyTokenIfUnset,
+		uv.hmacEmailVerifyToken,
 	)
 	if err != nil {
 		return err
@@ -228,11 +465,42 @@ func (uv *userValidator) Create(user *User) error {
 	return uv.UserDB.Create(user)
 }
 
+// ByEmailVerifyToken hashes the provided token and looks up the
+// matching user. It returns ErrNotFound if the token is unknown or
+// has already expired.
+func (uv *userValidator) ByEmailVerifyToken(token string) (*User, error) {
+	if token == "" {
+		return nil, ErrNotFound
+	}
+	user := User{
+		EmailVerifyToken: token,
+	}
+	if err := runUserValFns(&user, uv.hmacEmailVerifyToken); err != nil {
+		return nil, err
+	}
+	foundUser, err := uv.UserDB.ByEmailVerifyToken(*user.EmailVerifyTokenHash)
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().After(foundUser.EmailVerifyTokenExpires) {
+		return nil, ErrNotFound
+	}
+	return foundUser, nil
+}
+
 // Update will hash a remember token if it is provided
 func (uv *userValidator) Update(user *User) error {
 	err := runUserValFns(user,
-		uv.bcryptPassword,
-		uv.hmacRemember)
+		uv.idGreaterThan(0),
+		uv.emailNormalize,
+		uv.emailFormat,
+		uv.emailIsAvail,
+		uv.passwordMinLength,
+		uv.hashPassword,
+		uv.passwordHashRequired,
+		uv.hmacRemember,
+		uv.rememberMinBytes,
+	)
 	if err != nil {
 		return err
 	}
@@ -256,22 +524,6 @@ type userGorm struct {
 	db *gorm.DB
 }
 
-func newUserGorm(connectionInfo string) (*userGorm, error) {
-	db, err := gorm.Open("postgres", connectionInfo)
-	if err != nil {
-		return nil, err
-	}
-	db.LogMode(true)
-	return &userGorm{
-		db: db,
-	}, nil
-}
-
-// Closes the UserService database connection
-func (ug *userGorm) Close() error {
-	return ug.db.Close()
-}
-
 // ByID will look up a user with the provided ID.
 // If the user is found, we will return a nil error
 // If the user is not found, we will return ErrNotFound
@@ -321,13 +573,15 @@ func (ug *userGorm) ByRemember(rememberHash string) (*User, error) {
 	return &user, nil
 }
 
-// DestructiveReset drops the user table and rebuilds it
-func (ug *userGorm) DestructiveReset() error {
-	err := ug.db.DropTableIfExists(&User{}).Error
+// ByEmailVerifyToken looks up a user with the given (already
+// hashed) email verification token.
+func (ug *userGorm) ByEmailVerifyToken(tokenHash string) (*User, error) {
+	var user User
+	err := first(ug.db.Where("email_verify_token_hash = ?", tokenHash), &user)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return ug.AutoMigrate()
+	return &user, nil
 }
 
 // Create will create the provided user and backfill data like the ID, CreatedAt, and UpdatedAt fields.
@@ -335,6 +589,33 @@ func (ug *userGorm) Create(user *User) error {
 	return ug.db.Create(user).Error
 }
 
+// Search returns the page'th page (1-indexed) of users whose name
+// or email contains query, ordered by ID, along with the total
+// number of matching rows.
+func (ug *userGorm) Search(query string, page, pageSize int) ([]User, int, error) {
+	if page < 1 {
+		page = 1
+	}
+	db := ug.db.Model(&User{})
+	if query != "" {
+		like := "%" + query + "%"
+		db = db.Where("name LIKE ? OR email LIKE ?", like, like)
+	}
+	var total int
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var users []User
+	err := db.Order("id").
+		Limit(pageSize).
+		Offset((page - 1) * pageSize).
+		Find(&users).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
 // first will query the provided gorm.DB and it will get the first item returned
 // and place it into dst. If nothing is found in the query, it will return ErrNotFound
 func first(db *gorm.DB, dst interface{}) error {
@@ -355,11 +636,3 @@ func (ug *userGorm) Delete(id uint) error {
 	user := User{Model: gorm.Model{ID: id}}
 	return ug.db.Delete(&user).Error
 }
-
-// AutoMigrate will attempt to automatically migrate the users table
-func (ug *userGorm) AutoMigrate() error {
-	if err := ug.db.AutoMigrate(&User{}).Error; err != nil {
-		return err
-	}
-	return nil
-}
@@ -0,0 +1,212 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/uhdang/lenslocked/hash"
+	"github.com/uhdang/lenslocked/rand"
+)
+
+// stubUserDB is a minimal UserDB used to exercise userValidator
+// methods without a real database connection. Only ByEmail is
+// implemented with any real behavior, since emailIsAvail is the
+// only validator that needs to look anything up.
+type stubUserDB struct {
+	byEmail map[string]*User
+}
+
+func (s *stubUserDB) ByID(id uint) (*User, error) { return nil, ErrNotFound }
+
+func (s *stubUserDB) ByEmail(email string) (*User, error) {
+	if user, ok := s.byEmail[email]; ok {
+		return user, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (s *stubUserDB) ByRemember(token string) (*User, error) { return nil, ErrNotFound }
+
+func (s *stubUserDB) ByEmailVerifyToken(token string) (*User, error) { return nil, ErrNotFound }
+
+func (s *stubUserDB) Search(query string, page, pageSize int) ([]User, int, error) {
+	return nil, 0, nil
+}
+
+func (s *stubUserDB) Create(user *User) error { return nil }
+
+func (s *stubUserDB) Update(user *User) error { return nil }
+
+func (s *stubUserDB) Delete(id uint) error { return nil }
+
+func newTestUserValidator() *userValidator {
+	return &userValidator{
+		UserDB: &stubUserDB{byEmail: map[string]*User{}},
+		hmac:   hash.NewHMAC(hmacSecretKey),
+		hasher: NewHasher(DefaultPasswordHasherConfig),
+	}
+}
+
+func fieldErrorField(t *testing.T, err error) string {
+	t.Helper()
+	fe, ok := err.(*FieldError)
+	if !ok {
+		t.Fatalf("expected *FieldError, got %T (%v)", err, err)
+	}
+	return fe.Field
+}
+
+func TestEmailFormat(t *testing.T) {
+	uv := newTestUserValidator()
+
+	if err := uv.emailFormat(&User{Email: "foo@bar.com"}); err != nil {
+		t.Errorf("emailFormat(valid) = %v, want nil", err)
+	}
+
+	if err := uv.emailFormat(&User{Email: ""}); err == nil {
+		t.Error("emailFormat(empty) = nil, want error")
+	} else if field := fieldErrorField(t, err); field != "email" {
+		t.Errorf("emailFormat(empty) field = %q, want %q", field, "email")
+	}
+
+	if err := uv.emailFormat(&User{Email: "not-an-email"}); err == nil {
+		t.Error("emailFormat(invalid) = nil, want error")
+	} else if field := fieldErrorField(t, err); field != "email" {
+		t.Errorf("emailFormat(invalid) field = %q, want %q", field, "email")
+	}
+}
+
+func TestEmailNormalize(t *testing.T) {
+	uv := newTestUserValidator()
+	user := &User{Email: "  Foo@BAR.com  "}
+	if err := uv.emailNormalize(user); err != nil {
+		t.Fatalf("emailNormalize() = %v, want nil", err)
+	}
+	if user.Email != "foo@bar.com" {
+		t.Errorf("emailNormalize() = %q, want %q", user.Email, "foo@bar.com")
+	}
+}
+
+func TestEmailIsAvail(t *testing.T) {
+	uv := newTestUserValidator()
+	uv.UserDB = &stubUserDB{byEmail: map[string]*User{
+		"taken@bar.com": {Model: gorm.Model{ID: 1}, Email: "taken@bar.com"},
+	}}
+
+	if err := uv.emailIsAvail(&User{Email: "free@bar.com"}); err != nil {
+		t.Errorf("emailIsAvail(free) = %v, want nil", err)
+	}
+
+	if err := uv.emailIsAvail(&User{Model: gorm.Model{ID: 1}, Email: "taken@bar.com"}); err != nil {
+		t.Errorf("emailIsAvail(own email) = %v, want nil", err)
+	}
+
+	err := uv.emailIsAvail(&User{Model: gorm.Model{ID: 2}, Email: "taken@bar.com"})
+	if err == nil {
+		t.Fatal("emailIsAvail(taken by someone else) = nil, want error")
+	}
+	if field := fieldErrorField(t, err); field != "email" {
+		t.Errorf("emailIsAvail() field = %q, want %q", field, "email")
+	}
+}
+
+func TestPasswordMinLength(t *testing.T) {
+	uv := newTestUserValidator()
+
+	if err := uv.passwordMinLength(&User{Password: ""}); err != nil {
+		t.Errorf("passwordMinLength(unchanged) = %v, want nil", err)
+	}
+
+	if err := uv.passwordMinLength(&User{Password: "short"}); err == nil {
+		t.Error("passwordMinLength(short) = nil, want error")
+	} else if field := fieldErrorField(t, err); field != "password" {
+		t.Errorf("passwordMinLength(short) field = %q, want %q", field, "password")
+	}
+
+	if err := uv.passwordMinLength(&User{Password: "longenough"}); err != nil {
+		t.Errorf("passwordMinLength(long enough) = %v, want nil", err)
+	}
+}
+
+func TestPasswordHashRequired(t *testing.T) {
+	uv := newTestUserValidator()
+
+	if err := uv.passwordHashRequired(&User{PasswordHash: ""}); err == nil {
+		t.Error("passwordHashRequired(empty) = nil, want error")
+	} else if field := fieldErrorField(t, err); field != "password" {
+		t.Errorf("passwordHashRequired(empty) field = %q, want %q", field, "password")
+	}
+
+	if err := uv.passwordHashRequired(&User{PasswordHash: "$bcrypt$pv=0$..."}); err != nil {
+		t.Errorf("passwordHashRequired(set) = %v, want nil", err)
+	}
+}
+
+func TestRememberMinBytes(t *testing.T) {
+	uv := newTestUserValidator()
+
+	if err := uv.rememberMinBytes(&User{Remember: ""}); err != nil {
+		t.Errorf("rememberMinBytes(unchanged) = %v, want nil", err)
+	}
+
+	if err := uv.rememberMinBytes(&User{Remember: "not-base64-!!"}); err == nil {
+		t.Error("rememberMinBytes(invalid encoding) = nil, want error")
+	} else if field := fieldErrorField(t, err); field != "remember" {
+		t.Errorf("rememberMinBytes(invalid encoding) field = %q, want %q", field, "remember")
+	}
+
+	short := strings.Repeat("A", 4)
+	if err := uv.rememberMinBytes(&User{Remember: short}); err == nil {
+		t.Error("rememberMinBytes(too short) = nil, want error")
+	} else if field := fieldErrorField(t, err); field != "remember" {
+		t.Errorf("rememberMinBytes(too short) field = %q, want %q", field, "remember")
+	}
+
+	token, err := rand.RememberToken()
+	if err != nil {
+		t.Fatalf("rand.RememberToken() = %v", err)
+	}
+	if err := uv.rememberMinBytes(&User{Remember: token}); err != nil {
+		t.Errorf("rememberMinBytes(valid) = %v, want nil", err)
+	}
+}
+
+func TestIDGreaterThan(t *testing.T) {
+	uv := newTestUserValidator()
+	fn := uv.idGreaterThan(0)
+
+	if err := fn(&User{Model: gorm.Model{ID: 0}}); err != ErrInvalidID {
+		t.Errorf("idGreaterThan(0)(id=0) = %v, want %v", err, ErrInvalidID)
+	}
+
+	if err := fn(&User{Model: gorm.Model{ID: 1}}); err != nil {
+		t.Errorf("idGreaterThan(0)(id=1) = %v, want nil", err)
+	}
+}
+
+func TestRunUserValFnsCollectsFieldErrors(t *testing.T) {
+	uv := newTestUserValidator()
+	err := runUserValFns(&User{Email: "", Password: "short"},
+		uv.emailFormat,
+		uv.passwordMinLength,
+	)
+	ve, ok := err.(ValidationError)
+	if !ok {
+		t.Fatalf("runUserValFns() error type = %T, want ValidationError", err)
+	}
+	if _, ok := ve["email"]; !ok {
+		t.Error("runUserValFns() missing email error")
+	}
+	if _, ok := ve["password"]; !ok {
+		t.Error("runUserValFns() missing password error")
+	}
+}
+
+func TestRunUserValFnsAbortsOnNonFieldError(t *testing.T) {
+	uv := newTestUserValidator()
+	err := runUserValFns(&User{Model: gorm.Model{ID: 0}}, uv.idGreaterThan(0), uv.emailFormat)
+	if err != ErrInvalidID {
+		t.Errorf("runUserValFns() = %v, want %v", err, ErrInvalidID)
+	}
+}
@@ -0,0 +1,29 @@
+package models
+
+import "strings"
+
+// FieldError is a validation failure tied to a single form field,
+// so callers can surface it inline next to the offending input
+// instead of as one opaque error message.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (fe *FieldError) Error() string {
+	return fe.Field + " " + fe.Message
+}
+
+// ValidationError maps a field name to the message that should be
+// shown next to it. It is returned instead of a plain error by
+// Create/Update/Delete whenever one or more field-level checks
+// fail, so templates can render every problem at once.
+type ValidationError map[string]string
+
+func (ve ValidationError) Error() string {
+	msgs := make([]string, 0, len(ve))
+	for field, msg := range ve {
+		msgs = append(msgs, field+" "+msg)
+	}
+	return strings.Join(msgs, "; ")
+}
@@ -0,0 +1,101 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/github"
+)
+
+const (
+	githubUserAPIURL   = "https://api.github.com/user"
+	githubEmailsAPIURL = "https://api.github.com/user/emails"
+)
+
+// NewGitHubProvider builds a Provider for "Sign in with GitHub"
+// using the given OAuth2 client credentials and redirect URL.
+func NewGitHubProvider(clientID, clientSecret, redirectURL string) *Provider {
+	return &Provider{
+		Name: "github",
+		Config: &oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			RedirectURL:  redirectURL,
+			Scopes:       []string{"read:user", "user:email"},
+			Endpoint:     github.Endpoint,
+		},
+		FetchIdentity: fetchGitHubIdentity,
+	}
+}
+
+func fetchGitHubIdentity(ctx context.Context, token *oauth2.Token) (*Identity, error) {
+	var body struct {
+		ID    int    `json:"id"`
+		Email string `json:"email"`
+		Name  string `json:"name"`
+	}
+	if err := getGitHubJSON(ctx, token, githubUserAPIURL, &body); err != nil {
+		return nil, err
+	}
+	email := body.Email
+	if email == "" {
+		// GitHub only returns /user's email field when the user has
+		// made an email address public; otherwise it's "" even with
+		// the user:email scope, so fall back to the emails endpoint
+		// and pick the primary, verified address.
+		var err error
+		email, err = fetchGitHubPrimaryEmail(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &Identity{
+		Subject: strconv.Itoa(body.ID),
+		Email:   email,
+		Name:    body.Name,
+	}, nil
+}
+
+// fetchGitHubPrimaryEmail looks up the user's primary, verified
+// email via the emails endpoint, falling back to the first verified
+// address if none is marked primary.
+func fetchGitHubPrimaryEmail(ctx context.Context, token *oauth2.Token) (string, error) {
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := getGitHubJSON(ctx, token, githubEmailsAPIURL, &emails); err != nil {
+		return "", err
+	}
+	var firstVerified string
+	for _, e := range emails {
+		if !e.Verified {
+			continue
+		}
+		if e.Primary {
+			return e.Email, nil
+		}
+		if firstVerified == "" {
+			firstVerified = e.Email
+		}
+	}
+	return firstVerified, nil
+}
+
+func getGitHubJSON(ctx context.Context, token *oauth2.Token, url string, dst interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	token.SetAuthHeader(req)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(dst)
+}
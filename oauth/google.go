@@ -0,0 +1,55 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const googleUserInfoURL = "https://www.googleapis.com/oauth2/v3/userinfo"
+
+// NewGoogleProvider builds a Provider for "Sign in with Google"
+// using the given OAuth2 client credentials and redirect URL.
+func NewGoogleProvider(clientID, clientSecret, redirectURL string) *Provider {
+	return &Provider{
+		Name: "google",
+		Config: &oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			RedirectURL:  redirectURL,
+			Scopes:       []string{"openid", "email", "profile"},
+			Endpoint:     google.Endpoint,
+		},
+		FetchIdentity: fetchGoogleIdentity,
+	}
+}
+
+func fetchGoogleIdentity(ctx context.Context, token *oauth2.Token) (*Identity, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, googleUserInfoURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	token.SetAuthHeader(req)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Sub   string `json:"sub"`
+		Email string `json:"email"`
+		Name  string `json:"name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	return &Identity{
+		Subject: body.Sub,
+		Email:   body.Email,
+		Name:    body.Name,
+	}, nil
+}
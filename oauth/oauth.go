@@ -0,0 +1,62 @@
+// Package oauth provides a registry of third-party identity
+// providers (Google, GitHub, or any generic OIDC provider) that
+// controllers can use to support "Sign in with..." alongside
+// email/password login.
+package oauth
+
+import (
+	"context"
+
+	"golang.org/x/oauth2"
+)
+
+// Identity is the subset of profile data we need from a
+// third-party identity provider once a code has been exchanged for
+// an access token.
+type Identity struct {
+	// Subject is the provider's stable, unique identifier for the
+	// authenticated account.
+	Subject string
+	Email   string
+	Name    string
+}
+
+// Provider couples an OAuth2 client configuration with the logic
+// needed to turn a token from that provider into an Identity.
+type Provider struct {
+	// Name identifies the provider in routes, e.g. "google" in
+	// /oauth/google/login.
+	Name   string
+	Config *oauth2.Config
+
+	// FetchIdentity exchanges an already-obtained token for the
+	// signed-in user's Identity, typically by calling the
+	// provider's userinfo endpoint.
+	FetchIdentity func(ctx context.Context, token *oauth2.Token) (*Identity, error)
+}
+
+// Registry is a lookup of configured Providers by name. A
+// deployment enables a provider by registering it at startup; no
+// code changes are required to add or remove one later.
+type Registry struct {
+	providers map[string]*Provider
+}
+
+// NewRegistry returns an empty Registry ready to have Providers
+// registered on it.
+func NewRegistry() *Registry {
+	return &Registry{
+		providers: make(map[string]*Provider),
+	}
+}
+
+// Register adds p to the registry, keyed by p.Name.
+func (r *Registry) Register(p *Provider) {
+	r.providers[p.Name] = p
+}
+
+// Get looks up a previously registered Provider by name.
+func (r *Registry) Get(name string) (*Provider, bool) {
+	p, ok := r.providers[name]
+	return p, ok
+}
@@ -0,0 +1,49 @@
+package rand
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+)
+
+// RememberTokenBytes is the number of bytes used to generate
+// each remember token.
+const RememberTokenBytes = 32
+
+// Bytes generates n random bytes, or returns an error if there
+// was one. This uses the crypto/rand package so it is safe to
+// use for remember tokens and the like.
+func Bytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// String generates a base64 URL-encoded string built from the
+// provided number of random bytes.
+func String(nBytes int) (string, error) {
+	b, err := Bytes(nBytes)
+	if err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(b), nil
+}
+
+// RememberToken generates a byte slice of size RememberTokenBytes
+// and returns it as a base64 encoded string.
+func RememberToken() (string, error) {
+	return String(RememberTokenBytes)
+}
+
+// RecoveryCodeBytes is the number of bytes used to generate each
+// 2FA recovery code.
+const RecoveryCodeBytes = 10
+
+// RecoveryCode generates a byte slice of size RecoveryCodeBytes and
+// returns it as a base64 encoded string, suitable for a 2FA recovery
+// code shown to the user once and never stored in plaintext.
+func RecoveryCode() (string, error) {
+	return String(RecoveryCodeBytes)
+}
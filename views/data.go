@@ -0,0 +1,48 @@
+package views
+
+import "github.com/uhdang/lenslocked/models"
+
+const (
+	AlertLvlError   = "danger"
+	AlertLvlWarning = "warning"
+	AlertLvlInfo    = "info"
+	AlertLvlSuccess = "success"
+)
+
+// AlertMsgGeneric is displayed whenever an error isn't a
+// models.ValidationError, so we don't leak internal error details
+// to the user.
+const AlertMsgGeneric = "Something went wrong. Please try again, and contact us if the problem persists."
+
+// Alert is used to render alert messages in templates.
+type Alert struct {
+	Level   string
+	Message string
+}
+
+// Data is the top level structure that views expect data to come
+// in, so that we can provide an Alert, per-field errors, and the
+// Yield data together.
+type Data struct {
+	Alert       *Alert
+	FieldErrors models.ValidationError
+	Yield       interface{}
+}
+
+// SetAlert sets a danger-level Alert from err. If err is a
+// models.ValidationError, FieldErrors is populated instead of a
+// generic message so the template can render each problem inline.
+func (d *Data) SetAlert(err error) {
+	if ve, ok := err.(models.ValidationError); ok {
+		d.FieldErrors = ve
+		d.Alert = &Alert{
+			Level:   AlertLvlError,
+			Message: "Please fix the errors below.",
+		}
+		return
+	}
+	d.Alert = &Alert{
+		Level:   AlertLvlError,
+		Message: AlertMsgGeneric,
+	}
+}
@@ -0,0 +1,102 @@
+package views
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"io"
+	"net/http"
+	"path/filepath"
+
+	"github.com/gorilla/csrf"
+)
+
+var (
+	LayoutDir   = "views/layouts/"
+	TemplateDir = "views/"
+	TemplateExt = ".gohtml"
+)
+
+// NewView parses the provided layout and files, along with every
+// layout file found in LayoutDir, into a single *View.
+func NewView(layout string, files ...string) *View {
+	addTemplatePath(files)
+	addTemplateExt(files)
+	files = append(files, layoutFiles()...)
+
+	t, err := template.New("").Funcs(template.FuncMap{
+		"csrfField": func() (template.HTML, error) {
+			return "", errors.New("csrfField is not implemented")
+		},
+	}).ParseFiles(files...)
+	if err != nil {
+		panic(err)
+	}
+	return &View{
+		Template: t,
+		Layout:   layout,
+	}
+}
+
+type View struct {
+	Template *template.Template
+	Layout   string
+}
+
+// Render renders the view with the given data, executing the
+// view's Layout template. If r is non-nil, the {{csrfField}}
+// template function is wired up to emit a CSRF token for that
+// request.
+func (v *View) Render(w http.ResponseWriter, r *http.Request, data interface{}) error {
+	w.Header().Set("Content-Type", "text/html")
+	vd, ok := data.(Data)
+	if !ok {
+		vd = Data{Yield: data}
+	}
+	tpl := v.Template
+	if r != nil {
+		tpl = tpl.Funcs(template.FuncMap{
+			"csrfField": func() template.HTML {
+				return csrf.TemplateField(r)
+			},
+		})
+	}
+	var buf bytes.Buffer
+	if err := tpl.ExecuteTemplate(&buf, v.Layout, vd); err != nil {
+		return err
+	}
+	_, err := io.Copy(w, &buf)
+	return err
+}
+
+func (v *View) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := v.Render(w, r, nil); err != nil {
+		panic(err)
+	}
+}
+
+// layoutFiles returns a slice of strings representing the layout
+// files used in our application.
+func layoutFiles() []string {
+	files, err := filepath.Glob(LayoutDir + "*" + TemplateExt)
+	if err != nil {
+		panic(err)
+	}
+	return files
+}
+
+// addTemplatePath takes in a slice of strings representing file
+// paths and prepends TemplateDir to each string.
+func addTemplatePath(files []string) {
+	for i, f := range files {
+		files[i] = TemplateDir + f
+	}
+}
+
+// addTemplateExt takes in a slice of strings representing file
+// paths and appends TemplateExt to each string.
+func addTemplateExt(files []string) {
+	for i, f := range files {
+		files[i] = f + TemplateExt
+	}
+}